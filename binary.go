@@ -0,0 +1,97 @@
+package httpbaselinetest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// binaryExtensions are the sidecar file extensions for common binary
+// media types. Other binary bodies use .bin.
+var binaryExtensions = map[string]string{
+	"application/gzip": ".gz",
+	"application/pdf":  ".pdf",
+	"application/zip":  ".zip",
+	"audio/mpeg":       ".mp3",
+	"font/woff":        ".woff",
+	"font/woff2":       ".woff2",
+	"image/gif":        ".gif",
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/webp":       ".webp",
+	"video/mp4":        ".mp4",
+}
+
+// binaryBody is a body written to a sidecar file instead of the
+// text baseline
+type binaryBody struct {
+	ext  string
+	data []byte
+}
+
+// isBinaryBody reports whether a body without a formatter should be
+// kept out of the text baseline, going by its media type or, if that
+// says nothing, by its content
+func isBinaryBody(mediaType string, body []byte) bool {
+	if _, ok := binaryExtensions[mediaType]; ok {
+		return true
+	}
+	switch strings.SplitN(mediaType, "/", 2)[0] {
+	case "image", "audio", "video", "font":
+		return true
+	}
+	if mediaType == "application/octet-stream" {
+		return true
+	}
+	return !utf8.Valid(body)
+}
+
+func binaryExtension(mediaType string, body []byte) string {
+	ext, ok := binaryExtensions[mediaType]
+	if !ok {
+		// sniff bodies sent without a useful content type
+		sniffed := strings.SplitN(http.DetectContentType(body), ";", 2)[0]
+		ext, ok = binaryExtensions[sniffed]
+	}
+	if !ok {
+		return ".bin"
+	}
+	return ext
+}
+
+func formatBinaryBody(body []byte) string {
+	return fmt.Sprintf("binary body:\n  size: %d\n  sha256: %x\n", len(body), sha256.Sum256(body))
+}
+
+// sidecarPath is the path of the file holding a binary body, e.g.
+// testdata/get_v1_logo.resp.body.png
+func (r *httpBaselineTestRunner) sidecarPath(artifact Artifact, binary *binaryBody) string {
+	return r.baselinePrefix + "." + string(artifact) + ".body" + binary.ext
+}
+
+func (r *httpBaselineTestRunner) writeSidecar(artifact Artifact, binary *binaryBody) {
+	if binary == nil {
+		return
+	}
+	r.writeFile(r.sidecarPath(artifact, binary), binary.data)
+}
+
+// writeMissingSidecar recreates the sidecar of a binary body that
+// matched its baseline, e.g. after the sidecar was deleted
+func (r *httpBaselineTestRunner) writeMissingSidecar(artifact Artifact, binary *binaryBody) {
+	if binary == nil {
+		return
+	}
+	path := r.sidecarPath(artifact, binary)
+	r.suite.fileLock.RLock()
+	_, err := os.Stat(path)
+	r.suite.fileLock.RUnlock()
+	if !os.IsNotExist(err) {
+		return
+	}
+	r.t.Logf("Creating missing sidecar %s", path)
+	r.writeFile(path, binary.data)
+}
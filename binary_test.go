@@ -0,0 +1,108 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsBinaryBody(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		body      []byte
+		binary    bool
+	}{
+		{"image/png", []byte("text"), true},
+		{"image/avif", []byte("text"), true},
+		{"font/otf", nil, true},
+		{"application/octet-stream", []byte("text"), true},
+		{"application/pdf", []byte("%PDF"), true},
+		{"text/plain", []byte("hello"), false},
+		{"", []byte("hello"), false},
+		{"", []byte{0xff, 0xfe, 0x00}, true},
+		{"text/plain", []byte{0xff}, true},
+	}
+	for _, tt := range tests {
+		if got := isBinaryBody(tt.mediaType, tt.body); got != tt.binary {
+			t.Errorf("isBinaryBody(%q, %q) = %v, want %v", tt.mediaType, tt.body, got, tt.binary)
+		}
+	}
+}
+
+func TestBinaryExtension(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	tests := []struct {
+		mediaType string
+		body      []byte
+		ext       string
+	}{
+		{"image/jpeg", nil, ".jpg"},
+		{"application/pdf", nil, ".pdf"},
+		{"application/octet-stream", png, ".png"},
+		{"", png, ".png"},
+		{"application/octet-stream", []byte{0, 1, 2}, ".bin"},
+		{"image/x-unknown", []byte{0, 1, 2}, ".bin"},
+	}
+	for _, tt := range tests {
+		if got := binaryExtension(tt.mediaType, tt.body); got != tt.ext {
+			t.Errorf("binaryExtension(%q) = %s, want %s", tt.mediaType, got, tt.ext)
+		}
+	}
+}
+
+func TestSidecarRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	logo := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x01")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(logo)
+	})
+	run := func(mode RebaselineMode) bool {
+		return t.Run("logo", func(t *testing.T) {
+			bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(mode))
+			bts.Run("get logo", HTTPBaselineTest{Handler: handler, Method: "GET", Path: "/logo"})
+		})
+	}
+	sidecar := filepath.Join(dir, "get_logo.resp.body.png")
+	assertSidecar := func() {
+		t.Helper()
+		data, err := ioutil.ReadFile(sidecar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, logo) {
+			t.Errorf("sidecar = %q, want %q", data, logo)
+		}
+	}
+
+	if !run(RebaselineAlways) {
+		t.Fatal("rebaselining failed")
+	}
+	assertSidecar()
+	resp, err := ioutil.ReadFile(filepath.Join(dir, "get_logo.resp.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp), formatBinaryBody(logo)) || bytes.Contains(resp, logo) {
+		t.Errorf("response baseline does not hold only the hash:\n%s", resp)
+	}
+
+	// the hash is compared, so a missing sidecar doesn't fail the test
+	if err := os.Remove(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if !run(RebaselineNever) {
+		t.Fatal("comparing without the sidecar failed")
+	}
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Error("sidecar was written without rebaselining")
+	}
+	if !run(RebaselineMissing) {
+		t.Fatal("rebaselining missing baselines failed")
+	}
+	assertSidecar()
+}
@@ -0,0 +1,123 @@
+package httpbaselinetest
+
+import (
+	"strings"
+)
+
+// baselineArchive is a combined baseline in txtar style: an optional
+// comment followed by sections that each start with a "-- name --"
+// line.
+type baselineArchive struct {
+	comment  string
+	sections []baselineSection
+}
+
+type baselineSection struct {
+	name string
+	data string
+}
+
+// sectionOrder is the order sections are written in. Other sections,
+// like notes added by hand, are kept after these.
+var sectionOrder = []string{"request", "response", "db"}
+
+func artifactSection(artifact Artifact) string {
+	switch artifact {
+	case ArtifactRequest:
+		return "request"
+	case ArtifactResponse:
+		return "response"
+	}
+	return string(artifact)
+}
+
+func sectionRank(name string) int {
+	for i, n := range sectionOrder {
+		if n == name {
+			return i
+		}
+	}
+	return len(sectionOrder)
+}
+
+func sectionHeader(line string) (string, bool) {
+	line = strings.TrimSuffix(line, "\n")
+	if !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") ||
+		len(line) < len("-- x --") {
+		return "", false
+	}
+	return strings.TrimSpace(line[3 : len(line)-3]), true
+}
+
+// findSectionHeader returns the first line of data that would be
+// read back as the start of a new section
+func findSectionHeader(data string) (string, bool) {
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if _, ok := sectionHeader(line); ok {
+			return strings.TrimSuffix(line, "\n"), true
+		}
+	}
+	return "", false
+}
+
+func parseBaselineArchive(data string) *baselineArchive {
+	a := &baselineArchive{}
+	var current *baselineSection
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if name, ok := sectionHeader(line); ok {
+			a.sections = append(a.sections, baselineSection{name: name})
+			current = &a.sections[len(a.sections)-1]
+			continue
+		}
+		if current == nil {
+			a.comment += line
+		} else {
+			current.data += line
+		}
+	}
+	return a
+}
+
+func (a *baselineArchive) section(name string) (string, bool) {
+	for _, s := range a.sections {
+		if s.name == name {
+			return s.data, true
+		}
+	}
+	return "", false
+}
+
+func (a *baselineArchive) setSection(name string, data string) {
+	for i := range a.sections {
+		if a.sections[i].name == name {
+			a.sections[i].data = data
+			return
+		}
+	}
+	i := 0
+	for i < len(a.sections) && sectionRank(a.sections[i].name) <= sectionRank(name) {
+		i++
+	}
+	a.sections = append(a.sections, baselineSection{})
+	copy(a.sections[i+1:], a.sections[i:])
+	a.sections[i] = baselineSection{name: name, data: data}
+}
+
+func (a *baselineArchive) format() string {
+	var b strings.Builder
+	b.WriteString(ensureTrailingNewline(a.comment))
+	for _, s := range a.sections {
+		b.WriteString("-- " + s.name + " --\n")
+		b.WriteString(ensureTrailingNewline(s.data))
+	}
+	return b.String()
+}
+
+// ensureTrailingNewline makes sure a section ends with a newline so
+// the next section header starts on its own line
+func ensureTrailingNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
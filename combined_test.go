@@ -0,0 +1,133 @@
+package httpbaselinetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBaselineArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		comment  string
+		sections []baselineSection
+	}{
+		{
+			name: "empty",
+			data: "",
+		},
+		{
+			name:    "comment only",
+			data:    "notes\n",
+			comment: "notes\n",
+		},
+		{
+			name:    "sections",
+			data:    "notes\n-- request --\nGET /\n-- response --\nHTTP/1.1 200 OK\n\nbody\n",
+			comment: "notes\n",
+			sections: []baselineSection{
+				{name: "request", data: "GET /\n"},
+				{name: "response", data: "HTTP/1.1 200 OK\n\nbody\n"},
+			},
+		},
+		{
+			name: "empty section and no trailing newline",
+			data: "-- request --\n-- db --\n{}",
+			sections: []baselineSection{
+				{name: "request"},
+				{name: "db", data: "{}"},
+			},
+		},
+		{
+			name: "not headers",
+			data: "-- request --\n--  --\n-- x\n --x--\n",
+			sections: []baselineSection{
+				{name: "request", data: "--  --\n-- x\n --x--\n"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		a := parseBaselineArchive(tt.data)
+		if a.comment != tt.comment {
+			t.Errorf("%s: comment = %q, want %q", tt.name, a.comment, tt.comment)
+		}
+		if !reflect.DeepEqual(a.sections, tt.sections) {
+			t.Errorf("%s: sections = %+v, want %+v", tt.name, a.sections, tt.sections)
+		}
+	}
+}
+
+func TestBaselineArchiveSetSection(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		section  string
+		value    string
+		expected string
+	}{
+		{
+			name:     "new archive",
+			data:     "",
+			section:  "request",
+			value:    "GET /\n",
+			expected: "-- request --\nGET /\n",
+		},
+		{
+			name:     "replace",
+			data:     "-- request --\nGET /\n-- response --\nold\n",
+			section:  "response",
+			value:    "new\n",
+			expected: "-- request --\nGET /\n-- response --\nnew\n",
+		},
+		{
+			name:     "insert in order",
+			data:     "-- request --\nGET /\n-- db --\n{}\n",
+			section:  "response",
+			value:    "HTTP/1.1 200 OK\n",
+			expected: "-- request --\nGET /\n-- response --\nHTTP/1.1 200 OK\n-- db --\n{}\n",
+		},
+		{
+			name:     "insert before other sections",
+			data:     "-- request --\nGET /\n-- notes --\nby hand\n",
+			section:  "db",
+			value:    "{}",
+			expected: "-- request --\nGET /\n-- db --\n{}\n-- notes --\nby hand\n",
+		},
+		{
+			name:     "keep comment",
+			data:     "notes",
+			section:  "request",
+			value:    "GET /\n",
+			expected: "notes\n-- request --\nGET /\n",
+		},
+	}
+	for _, tt := range tests {
+		a := parseBaselineArchive(tt.data)
+		a.setSection(tt.section, tt.value)
+		if got := a.format(); got != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.expected)
+		}
+		if got, ok := a.section(tt.section); !ok || got != tt.value {
+			t.Errorf("%s: section(%q) = %q, %v", tt.name, tt.section, got, ok)
+		}
+	}
+}
+
+func TestFindSectionHeader(t *testing.T) {
+	tests := []struct {
+		data  string
+		line  string
+		found bool
+	}{
+		{"", "", false},
+		{"HTTP/1.1 200 OK\n\n-- not a header\n", "", false},
+		{"HTTP/1.1 200 OK\n\n-- end --\nmore\n", "-- end --", true},
+		{"body\n-- end --", "-- end --", true},
+	}
+	for _, tt := range tests {
+		line, found := findSectionHeader(tt.data)
+		if line != tt.line || found != tt.found {
+			t.Errorf("findSectionHeader(%q) = %q, %v, want %q, %v", tt.data, line, found, tt.line, tt.found)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package httpbaselinetest
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
@@ -19,6 +20,28 @@ type formattedDbBaseline struct {
 	NumRowsDeleted  uint64        `json:"numRowsDeleted"`
 	RemovedRows     []interface{} `json:"removedRows"`
 	AddedRows       []interface{} `json:"addedRows"`
+	UpdatedRows     []updatedRow  `json:"updatedRows,omitempty"`
+	// rows with the same primary key before and after, turned
+	// into UpdatedRows by formatDb
+	updatedRowPairs []updatedRowPair
+}
+
+type updatedRowPair struct {
+	primaryKey []string
+	before     interface{}
+	after      interface{}
+}
+
+// updatedRow is a row whose primary key stayed the same, with only
+// the columns that changed
+type updatedRow struct {
+	Key     map[string]interface{}   `json:"key"`
+	Changed map[string]columnChanged `json:"changed"`
+}
+
+type columnChanged struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
 }
 
 type pgStatUserTableInsUpdDel struct {
@@ -38,6 +61,8 @@ type JSONTableData map[string]bool
 type pgBaselineData struct {
 	PgInsUpdDel     *pgStatUserTableInsUpdDel
 	BeforeTableData *JSONTableData
+	// empty if the table has no primary key
+	PrimaryKey []string
 }
 
 type pgBaselineMap map[string]pgBaselineData
@@ -150,6 +175,24 @@ func dependencyOrder(depMap map[string][]string) []string {
 	return deps
 }
 
+// getPrimaryKey returns the primary key columns of a table, resolving
+// the table name through the search path like getJSONTableData does
+func getPrimaryKey(db *sqlx.DB, tableName string) ([]string, error) {
+	sql := `
+SELECT a.attname
+FROM pg_index i
+JOIN pg_attribute a
+          ON a.attrelid = i.indrelid
+          AND a.attnum = ANY(i.indkey)
+WHERE i.indrelid = quote_ident($1)::regclass
+  AND i.indisprimary
+ORDER BY array_position(i.indkey::int2[], a.attnum)
+`
+	primaryKey := []string{}
+	err := db.Select(&primaryKey, sql, tableName)
+	return primaryKey, err
+}
+
 func getJSONTableData(db *sqlx.DB, tableName string, jsonTableData *JSONTableData) error {
 	sql := `SELECT to_jsonb("` + tableName + `".*) AS json_data FROM "` +
 		tableName + `" ORDER BY 1`
@@ -168,39 +211,163 @@ func getJSONTableData(db *sqlx.DB, tableName string, jsonTableData *JSONTableDat
 	return nil
 }
 
-func buildFormattedDbBaseline(pgInsUpDel pgStatUserTableInsUpdDel,
-	removedRows []string, addedRows []string) (formattedDbBaseline, error) {
-	removedRowsJSON := make([]interface{}, len(removedRows))
-	addedRowsJSON := make([]interface{}, len(addedRows))
-	sort.Strings(removedRows)
-	for i := range removedRows {
+func decodeRows(rows []string) ([]interface{}, error) {
+	sort.Strings(rows)
+	rowsJSON := make([]interface{}, len(rows))
+	for i := range rows {
 		var v interface{}
-		err := json.Unmarshal([]byte(removedRows[i]), &v)
+		err := json.Unmarshal([]byte(rows[i]), &v)
 		if err != nil {
-			return formattedDbBaseline{}, err
+			return nil, err
 		}
-		removedRowsJSON[i] = v
+		rowsJSON[i] = v
 	}
-	sort.Strings(addedRows)
-	for i := range addedRows {
-		var v interface{}
-		err := json.Unmarshal([]byte(addedRows[i]), &v)
-		if err != nil {
-			return formattedDbBaseline{}, err
+	return rowsJSON, nil
+}
+
+// primaryKeyString returns the canonical JSON of a row's primary key
+// columns, or false if the row is missing one of them
+func primaryKeyString(row interface{}, primaryKey []string) (string, bool) {
+	m, ok := row.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	key := make([]interface{}, len(primaryKey))
+	for i, column := range primaryKey {
+		key[i], ok = m[column]
+		if !ok {
+			return "", false
 		}
-		addedRowsJSON[i] = v
+	}
+	return canonicalJSON(key), true
+}
+
+func buildFormattedDbBaseline(pgInsUpDel pgStatUserTableInsUpdDel, primaryKey []string,
+	removedRows []string, addedRows []string) (formattedDbBaseline, error) {
+	removedRowsJSON, err := decodeRows(removedRows)
+	if err != nil {
+		return formattedDbBaseline{}, err
+	}
+	addedRowsJSON, err := decodeRows(addedRows)
+	if err != nil {
+		return formattedDbBaseline{}, err
 	}
 	fdb := formattedDbBaseline{
 		NumRowsInserted: pgInsUpDel.NTupIns,
 		NumRowsUpdated:  pgInsUpDel.NTupUpd,
 		NumRowsDeleted:  pgInsUpDel.NTupDel,
-		RemovedRows:     removedRowsJSON,
-		AddedRows:       addedRowsJSON,
+		RemovedRows:     []interface{}{},
+		AddedRows:       []interface{}{},
+	}
+	if len(primaryKey) == 0 {
+		fdb.RemovedRows = removedRowsJSON
+		fdb.AddedRows = addedRowsJSON
+		return fdb, nil
+	}
+	// an updated row shows up as removed and added with the same
+	// primary key
+	removedByKey := make(map[string]interface{})
+	for _, row := range removedRowsJSON {
+		if key, ok := primaryKeyString(row, primaryKey); ok {
+			removedByKey[key] = row
+		}
+	}
+	paired := make(map[string]bool)
+	for _, row := range addedRowsJSON {
+		key, ok := primaryKeyString(row, primaryKey)
+		before, wasRemoved := removedByKey[key]
+		if !ok || !wasRemoved {
+			fdb.AddedRows = append(fdb.AddedRows, row)
+			continue
+		}
+		paired[key] = true
+		fdb.updatedRowPairs = append(fdb.updatedRowPairs, updatedRowPair{
+			primaryKey: primaryKey,
+			before:     before,
+			after:      row,
+		})
+	}
+	for _, row := range removedRowsJSON {
+		if key, ok := primaryKeyString(row, primaryKey); !ok || !paired[key] {
+			fdb.RemovedRows = append(fdb.RemovedRows, row)
+		}
 	}
 	return fdb, nil
 }
 
-func formatDb(fullDbBaseline map[string]formattedDbBaseline) ([]byte, error) {
+// updatedRows builds UpdatedRows from the transformed before and
+// after rows, sorted by primary key
+func (fo *formatOptions) updatedRows(pairs []updatedRowPair, transform jsonTransform) ([]updatedRow, error) {
+	type keyedRow struct {
+		key string
+		row updatedRow
+	}
+	keyed := make([]keyedRow, 0, len(pairs))
+	for _, pair := range pairs {
+		before, after := pair.before, pair.after
+		if transform != nil {
+			var err error
+			before, err = transform(before)
+			if err != nil {
+				return nil, err
+			}
+			after, err = transform(after)
+			if err != nil {
+				return nil, err
+			}
+		}
+		beforeColumns, _ := before.(map[string]interface{})
+		afterColumns, _ := after.(map[string]interface{})
+		row := updatedRow{
+			Key:     make(map[string]interface{}),
+			Changed: make(map[string]columnChanged),
+		}
+		for _, column := range pair.primaryKey {
+			row.Key[column] = afterColumns[column]
+		}
+		for column, value := range afterColumns {
+			if canonicalJSON(beforeColumns[column]) != canonicalJSON(value) {
+				row.Changed[column] = columnChanged{Old: beforeColumns[column], New: value}
+			}
+		}
+		// only scrubbed columns changed
+		if len(row.Changed) == 0 {
+			continue
+		}
+		key, _ := primaryKeyString(after, pair.primaryKey)
+		if fo != nil && fo.uuidAliases != nil {
+			key = fo.uuidAliases.mask(key)
+		}
+		keyed = append(keyed, keyedRow{key: key, row: row})
+	}
+	sort.SliceStable(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+	rows := make([]updatedRow, len(keyed))
+	for i := range keyed {
+		rows[i] = keyed[i].row
+	}
+	return rows, nil
+}
+
+func formatDb(fullDbBaseline map[string]formattedDbBaseline, fo *formatOptions) ([]byte, error) {
+	transform := fo.jsonTransform(ArtifactDb)
+	for tableName, tableDbBaseline := range fullDbBaseline {
+		var err error
+		tableDbBaseline.RemovedRows, err = fo.transformRows(tableDbBaseline.RemovedRows, transform)
+		if err != nil {
+			return nil, err
+		}
+		tableDbBaseline.AddedRows, err = fo.transformRows(tableDbBaseline.AddedRows, transform)
+		if err != nil {
+			return nil, err
+		}
+		tableDbBaseline.UpdatedRows, err = fo.updatedRows(tableDbBaseline.updatedRowPairs, transform)
+		if err != nil {
+			return nil, err
+		}
+		fullDbBaseline[tableName] = tableDbBaseline
+	}
 	// use encoder to add trailing newline
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -213,11 +380,14 @@ func formatDb(fullDbBaseline map[string]formattedDbBaseline) ([]byte, error) {
 }
 
 func (r *httpBaselineTestRunner) seedWithPolluter() {
-	f, err := os.Open(r.seedPath)
+	r.suite.fileLock.RLock()
+	seed, err := ioutil.ReadFile(r.seedPath)
+	r.suite.fileLock.RUnlock()
 	if err != nil {
 		r.t.Fatalf("Error opening seed file '%s': %s", r.seedPath, err)
 	}
-	defer f.Close()
+	r.formatOptions.seedData(string(seed))
+	f := bytes.NewReader(seed)
 	p := polluter.New(polluter.PostgresEngine(r.btest.Db.DB))
 	err = p.Pollute(f)
 	if err != nil {
@@ -234,6 +404,7 @@ func (r *httpBaselineTestRunner) getDbTableInfo() {
 	r.dbTableInfo.InitialTableNames = make([]string, len(beforeTableStats))
 	r.dbTableInfo.PgBaseline = make(pgBaselineMap)
 	jsonTableDataMap := make(map[string]*JSONTableData)
+	primaryKeyMap := make(map[string][]string)
 	for _, tableName := range r.btest.Tables {
 		jtd := make(JSONTableData)
 		err := getJSONTableData(r.btest.Db, tableName, &jtd)
@@ -241,6 +412,10 @@ func (r *httpBaselineTestRunner) getDbTableInfo() {
 			r.t.Fatalf("Error getting data for %s: %s", tableName, err)
 		}
 		jsonTableDataMap[tableName] = &jtd
+		primaryKeyMap[tableName], err = getPrimaryKey(r.btest.Db, tableName)
+		if err != nil {
+			r.t.Fatalf("Error getting primary key for %s: %s", tableName, err)
+		}
 	}
 	for i := range beforeTableStats {
 		tableName := beforeTableStats[i].Relname
@@ -249,6 +424,7 @@ func (r *httpBaselineTestRunner) getDbTableInfo() {
 		r.dbTableInfo.PgBaseline[tableName] = pgBaselineData{
 			PgInsUpdDel:     &beforeTableStats[i],
 			BeforeTableData: jtd,
+			PrimaryKey:      primaryKeyMap[tableName],
 		}
 	}
 	sort.Strings(r.dbTableInfo.InitialTableNames)
@@ -289,6 +465,13 @@ func (r *httpBaselineTestRunner) dbTestSetup() {
 		r.seedWithPolluter()
 	}
 	r.getDbTableInfo()
+	for _, baselineData := range r.dbTableInfo.PgBaseline {
+		if baselineData.BeforeTableData != nil {
+			for row := range *baselineData.BeforeTableData {
+				r.formatOptions.seedData(row)
+			}
+		}
+	}
 }
 
 func (r *httpBaselineTestRunner) generateDbBaseline() dbBaseline {
@@ -320,7 +503,7 @@ func (r *httpBaselineTestRunner) generateDbBaseline() dbBaseline {
 		diffPgInsUpdDel := pgStatUserTableInsUpdDel{
 			Relname: tableName,
 			NTupIns: afterPgInsUpdDel.NTupIns - beforePgInsUpdDel.NTupIns,
-			NTupUpd: afterPgInsUpdDel.NTupDel - beforePgInsUpdDel.NTupDel,
+			NTupUpd: afterPgInsUpdDel.NTupUpd - beforePgInsUpdDel.NTupUpd,
 			NTupDel: afterPgInsUpdDel.NTupDel - beforePgInsUpdDel.NTupDel,
 		}
 		if r.dbTableInfo.PgBaseline[tableName].BeforeTableData != nil {
@@ -343,7 +526,9 @@ func (r *httpBaselineTestRunner) generateDbBaseline() dbBaseline {
 					addedRows = append(addedRows, row)
 				}
 			}
-			tableDbBaseline, err := buildFormattedDbBaseline(diffPgInsUpdDel, removedRows, addedRows)
+			primaryKey := r.dbTableInfo.PgBaseline[tableName].PrimaryKey
+			tableDbBaseline, err := buildFormattedDbBaseline(diffPgInsUpdDel, primaryKey,
+				removedRows, addedRows)
 			if err != nil {
 				r.t.Fatalf("Error building formatted db baseline %s", err)
 			}
@@ -378,8 +563,13 @@ func (r *httpBaselineTestRunner) dumpForPolluter() {
 	dbBaseline := r.generateDbBaseline()
 	polluterMap := make(map[string][]interface{})
 	for tableName := range dbBaseline {
-		if len(dbBaseline[tableName].AddedRows) > 0 {
-			polluterMap[tableName] = dbBaseline[tableName].AddedRows
+		// the seed has the new version of updated rows
+		rows := dbBaseline[tableName].AddedRows
+		for _, pair := range dbBaseline[tableName].updatedRowPairs {
+			rows = append(rows, pair.after)
+		}
+		if len(rows) > 0 {
+			polluterMap[tableName] = rows
 		}
 	}
 	tableDeps, err := getTableDependencyOrder(r.btest.Db)
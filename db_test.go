@@ -0,0 +1,123 @@
+package httpbaselinetest
+
+import (
+	"testing"
+)
+
+func TestFormatDbAliasedRowOrder(t *testing.T) {
+	low := "00000000-0000-4000-8000-000000000001"
+	high := "ffffffff-ffff-4fff-bfff-ffffffffffff"
+	format := func(first string, second string) string {
+		fdb, err := buildFormattedDbBaseline(pgStatUserTableInsUpdDel{NTupIns: 2}, []string{"id"},
+			nil, []string{
+				`{"id": "` + first + `", "name": "a"}`,
+				`{"id": "` + second + `", "name": "b"}`,
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fo := &formatOptions{uuidAliases: newUUIDAliaser()}
+		data, err := formatDb(map[string]formattedDbBaseline{"cars": fdb}, fo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fo.aliasUUIDs(string(data))
+	}
+	a, b := format(low, high), format(high, low)
+	if a != b {
+		t.Errorf("row order depends on UUID values:\n%s\n%s", a, b)
+	}
+}
+
+func TestBuildFormattedDbBaseline(t *testing.T) {
+	tests := []struct {
+		name       string
+		primaryKey []string
+		removed    []string
+		added      []string
+		expected   string
+	}{
+		{
+			name:       "no primary key",
+			primaryKey: nil,
+			removed:    []string{`{"id": 1, "color": "red"}`},
+			added:      []string{`{"id": 1, "color": "blue"}`},
+			expected: `{"numRowsInserted":0,"numRowsUpdated":0,"numRowsDeleted":0,` +
+				`"removedRows":[{"color":"red","id":1}],"addedRows":[{"color":"blue","id":1}]}`,
+		},
+		{
+			name:       "updated row",
+			primaryKey: []string{"id"},
+			removed:    []string{`{"id": 1, "color": "red", "make": "Honda"}`, `{"id": 2, "color": "red"}`},
+			added:      []string{`{"id": 1, "color": "blue", "make": "Honda"}`, `{"id": 3, "color": "red"}`},
+			expected: `{"numRowsInserted":0,"numRowsUpdated":0,"numRowsDeleted":0,` +
+				`"removedRows":[{"color":"red","id":2}],"addedRows":[{"color":"red","id":3}],` +
+				`"updatedRows":[{"key":{"id":1},"changed":{"color":{"old":"red","new":"blue"}}}]}`,
+		},
+		{
+			name:       "composite key",
+			primaryKey: []string{"car_id", "owner_id"},
+			removed:    []string{`{"car_id": 1, "owner_id": 2, "since": 2019}`},
+			added: []string{
+				`{"car_id": 1, "owner_id": 2, "since": 2020}`,
+				`{"car_id": 2, "owner_id": 1, "since": 2019}`,
+			},
+			expected: `{"numRowsInserted":0,"numRowsUpdated":0,"numRowsDeleted":0,` +
+				`"removedRows":[],"addedRows":[{"car_id":2,"owner_id":1,"since":2019}],` +
+				`"updatedRows":[{"key":{"car_id":1,"owner_id":2},"changed":{"since":{"old":2019,"new":2020}}}]}`,
+		},
+		{
+			name:       "key changed",
+			primaryKey: []string{"id"},
+			removed:    []string{`{"id": 1, "color": "red"}`},
+			added:      []string{`{"id": 2, "color": "red"}`},
+			expected: `{"numRowsInserted":0,"numRowsUpdated":0,"numRowsDeleted":0,` +
+				`"removedRows":[{"color":"red","id":1}],"addedRows":[{"color":"red","id":2}]}`,
+		},
+		{
+			name:       "missing key column",
+			primaryKey: []string{"id"},
+			removed:    []string{`{"color": "red"}`},
+			added:      []string{`{"color": "blue"}`},
+			expected: `{"numRowsInserted":0,"numRowsUpdated":0,"numRowsDeleted":0,` +
+				`"removedRows":[{"color":"red"}],"addedRows":[{"color":"blue"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		fdb, err := buildFormattedDbBaseline(pgStatUserTableInsUpdDel{}, tt.primaryKey, tt.removed, tt.added)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		fdb.UpdatedRows, err = (*formatOptions)(nil).updatedRows(fdb.updatedRowPairs, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := canonicalJSON(fdb); got != tt.expected {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestUpdatedRowsScrubbed(t *testing.T) {
+	fdb, err := buildFormattedDbBaseline(pgStatUserTableInsUpdDel{}, []string{"id"},
+		[]string{`{"id": 1, "color": "red", "updated_at": "2020-01-01T00:00:00Z"}`,
+			`{"id": 2, "color": "red", "updated_at": "2020-01-01T00:00:00Z"}`},
+		[]string{`{"id": 1, "color": "red", "updated_at": "2020-01-02T00:00:00Z"}`,
+			`{"id": 2, "color": "blue", "updated_at": "2020-01-02T00:00:00Z"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrubbers, err := compileScrubbers([]Scrubber{ScrubTimestamp("$.updated_at")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fo := &formatOptions{scrubbers: scrubbers}
+	rows, err := fo.updatedRows(fdb.updatedRowPairs, fo.jsonTransform(ArtifactDb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"key":{"id":2},"changed":{"color":{"old":"red","new":"blue"}}}]`
+	if got := canonicalJSON(rows); got != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+}
@@ -0,0 +1,289 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3" // v3 keeps line numbers for error messages
+)
+
+// testDefinition is an HTTPBaselineTest loaded from a YAML or JSON
+// file. Only the request, seed and table fields can be defined in a
+// file; Handler, Db, Setup and the rest come from Go code.
+type testDefinition struct {
+	Name    string
+	Method  string
+	Path    string
+	Query   url.Values
+	Host    string
+	Headers map[string]string
+	Body    interface{}
+	Seed    string
+	Tables  []string
+}
+
+type definitionError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e *definitionError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+}
+
+func newDefinitionError(file string, node *yamlv3.Node, format string, args ...interface{}) error {
+	return &definitionError{
+		file: file,
+		line: node.Line,
+		msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// loadTestDefinitions parses a file containing either a list of
+// tests or a mapping with a "tests" list.
+func loadTestDefinitions(filename string) ([]testDefinition, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var doc yamlv3.Node
+	err = yamlv3.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s: no tests defined", filename)
+	}
+	root := doc.Content[0]
+	tests := root
+	if root.Kind == yamlv3.MappingNode {
+		tests = nil
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			if key.Value != "tests" {
+				return nil, newDefinitionError(filename, key, "unknown field %q", key.Value)
+			}
+			tests = value
+		}
+		if tests == nil {
+			return nil, newDefinitionError(filename, root, "missing field \"tests\"")
+		}
+	}
+	if tests.Kind != yamlv3.SequenceNode {
+		return nil, newDefinitionError(filename, tests, "tests must be a list")
+	}
+	defs := make([]testDefinition, 0, len(tests.Content))
+	for _, node := range tests.Content {
+		def, err := decodeTestDefinition(filename, node)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func decodeTestDefinition(filename string, node *yamlv3.Node) (testDefinition, error) {
+	def := testDefinition{}
+	if node.Kind != yamlv3.MappingNode {
+		return def, newDefinitionError(filename, node, "test must be a mapping")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		var err error
+		switch key.Value {
+		case "name":
+			def.Name, err = decodeScalar(filename, key.Value, value)
+		case "method":
+			def.Method, err = decodeScalar(filename, key.Value, value)
+			def.Method = strings.ToUpper(def.Method)
+		case "path":
+			def.Path, err = decodeScalar(filename, key.Value, value)
+		case "host":
+			def.Host, err = decodeScalar(filename, key.Value, value)
+		case "seed":
+			def.Seed, err = decodeScalar(filename, key.Value, value)
+		case "query":
+			def.Query, err = decodeQuery(filename, key.Value, value)
+		case "headers":
+			def.Headers, err = decodeStringMap(filename, key.Value, value)
+		case "tables":
+			def.Tables, err = decodeStringList(filename, key.Value, value)
+		case "body":
+			def.Body, err = decodeBody(filename, value)
+		default:
+			err = newDefinitionError(filename, key, "unknown field %q", key.Value)
+		}
+		if err != nil {
+			return def, err
+		}
+	}
+	if def.Name == "" {
+		return def, newDefinitionError(filename, node, "test is missing \"name\"")
+	}
+	if def.Method == "" {
+		return def, newDefinitionError(filename, node, "test %q is missing \"method\"", def.Name)
+	}
+	if def.Path == "" {
+		return def, newDefinitionError(filename, node, "test %q is missing \"path\"", def.Name)
+	}
+	return def, nil
+}
+
+func decodeScalar(filename string, field string, node *yamlv3.Node) (string, error) {
+	if node.Kind != yamlv3.ScalarNode {
+		return "", newDefinitionError(filename, node, "%s must be a string", field)
+	}
+	return node.Value, nil
+}
+
+func decodeStringMap(filename string, field string, node *yamlv3.Node) (map[string]string, error) {
+	if node.Kind != yamlv3.MappingNode {
+		return nil, newDefinitionError(filename, node, "%s must be a mapping", field)
+	}
+	m := make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		v, err := decodeScalar(filename, field+"."+key.Value, value)
+		if err != nil {
+			return nil, err
+		}
+		m[key.Value] = v
+	}
+	return m, nil
+}
+
+func decodeStringList(filename string, field string, node *yamlv3.Node) ([]string, error) {
+	if node.Kind != yamlv3.SequenceNode {
+		return nil, newDefinitionError(filename, node, "%s must be a list", field)
+	}
+	l := make([]string, len(node.Content))
+	for i, item := range node.Content {
+		v, err := decodeScalar(filename, field, item)
+		if err != nil {
+			return nil, err
+		}
+		l[i] = v
+	}
+	return l, nil
+}
+
+// decodeQuery accepts a single value or a list of values for each
+// query parameter
+func decodeQuery(filename string, field string, node *yamlv3.Node) (url.Values, error) {
+	if node.Kind != yamlv3.MappingNode {
+		return nil, newDefinitionError(filename, node, "%s must be a mapping", field)
+	}
+	query := make(url.Values)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		var values []string
+		var err error
+		if value.Kind == yamlv3.SequenceNode {
+			values, err = decodeStringList(filename, field+"."+key.Value, value)
+		} else {
+			var v string
+			v, err = decodeScalar(filename, field+"."+key.Value, value)
+			values = []string{v}
+		}
+		if err != nil {
+			return nil, err
+		}
+		query[key.Value] = values
+	}
+	return query, nil
+}
+
+// decodeBody keeps string bodies as is, and decodes everything else
+// so it is marshaled as JSON like any other HTTPBaselineTest.Body
+func decodeBody(filename string, node *yamlv3.Node) (interface{}, error) {
+	if node.Kind == yamlv3.ScalarNode && node.Tag == "!!str" {
+		return node.Value, nil
+	}
+	var body interface{}
+	err := node.Decode(&body)
+	if err != nil {
+		return nil, newDefinitionError(filename, node, "invalid body: %s", err)
+	}
+	// the body is sent as JSON, so catch what JSON can't encode,
+	// like .inf, while the line is still known
+	_, err = json.Marshal(body)
+	if err != nil {
+		return nil, newDefinitionError(filename, node, "invalid body: %s", err)
+	}
+	return body, nil
+}
+
+func (def *testDefinition) baselineTest(base HTTPBaselineTest) HTTPBaselineTest {
+	btest := base
+	btest.Method = def.Method
+	btest.Path = def.Path
+	if def.Query != nil {
+		btest.Query = def.Query
+	}
+	if def.Host != "" {
+		btest.Host = def.Host
+	}
+	if def.Headers != nil {
+		btest.Headers = make(map[string]string)
+		for k, v := range base.Headers {
+			btest.Headers[k] = v
+		}
+		for k, v := range def.Headers {
+			btest.Headers[k] = v
+		}
+	}
+	if def.Body != nil {
+		btest.Body = def.Body
+	}
+	if def.Seed != "" {
+		btest.Seed = def.Seed
+	}
+	if def.Tables != nil {
+		btest.Tables = def.Tables
+	}
+	return btest
+}
+
+// RunFile runs every test defined in a YAML or JSON file. Fields
+// that cannot be defined in a file, like Handler and Setup, are
+// taken from base.
+func (suite *Suite) RunFile(filename string, base HTTPBaselineTest) {
+	defs, err := loadTestDefinitions(filename)
+	if err != nil {
+		suite.t.Errorf("Error loading test definitions: %s", err)
+		return
+	}
+	for i := range defs {
+		suite.Run(defs[i].Name, defs[i].baselineTest(base))
+	}
+}
+
+// RunDir runs the tests in every .yaml, .yml and .json file in dir,
+// in file name order.
+func (suite *Suite) RunDir(dir string, base HTTPBaselineTest) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		suite.t.Fatalf("Error reading test definition dir %s: %s", dir, err)
+	}
+	filenames := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			filenames = append(filenames, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		suite.RunFile(filename, base)
+	}
+}
@@ -0,0 +1,118 @@
+package httpbaselinetest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeDefinitionFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tests.yaml")
+	err := ioutil.WriteFile(filename, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadTestDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		msg     string
+	}{
+		{
+			name:    "unknown top level field",
+			content: "tests: []\nextra: 1\n",
+			line:    2,
+			msg:     `unknown field "extra"`,
+		},
+		{
+			name:    "tests not a list",
+			content: "tests:\n  name: a\n",
+			line:    2,
+			msg:     "tests must be a list",
+		},
+		{
+			name:    "test not a mapping",
+			content: "- name: a\n  method: GET\n  path: /\n- just a string\n",
+			line:    4,
+			msg:     "test must be a mapping",
+		},
+		{
+			name:    "unknown field",
+			content: "- name: a\n  method: GET\n  path: /\n  bogus: 1\n",
+			line:    4,
+			msg:     `unknown field "bogus"`,
+		},
+		{
+			name:    "wrong node kind",
+			content: "- name: a\n  method: GET\n  path: /\n  headers:\n    - x\n",
+			line:    5,
+			msg:     "headers must be a mapping",
+		},
+		{
+			name:    "list where a string is expected",
+			content: "- name: [a]\n  method: GET\n  path: /\n",
+			line:    1,
+			msg:     "name must be a string",
+		},
+		{
+			name:    "missing name",
+			content: "- method: GET\n  path: /\n",
+			line:    1,
+			msg:     `test is missing "name"`,
+		},
+		{
+			name:    "missing method",
+			content: "- name: a\n  path: /\n- name: b\n  path: /\n",
+			line:    1,
+			msg:     `test "a" is missing "method"`,
+		},
+		{
+			name:    "missing path",
+			content: "- name: a\n  method: GET\n\n- name: b\n  method: GET\n",
+			line:    1,
+			msg:     `test "a" is missing "path"`,
+		},
+		{
+			name:    "query not a mapping",
+			content: "- name: a\n  method: GET\n  path: /\n  query: page=2\n",
+			line:    4,
+			msg:     "query must be a mapping",
+		},
+		{
+			name:    "query value not a string",
+			content: "- name: a\n  method: GET\n  path: /\n  query:\n    page:\n      n: 2\n",
+			line:    6,
+			msg:     "query.page must be a string",
+		},
+		{
+			name:    "query list item not a string",
+			content: "- name: a\n  method: GET\n  path: /\n  query:\n    tag:\n      - a\n      - [b]\n",
+			line:    7,
+			msg:     "query.tag must be a string",
+		},
+		{
+			name:    "non-JSON body",
+			content: "- name: a\n  method: GET\n  path: /\n  body:\n    price: .inf\n",
+			line:    5,
+			msg:     "invalid body: json: unsupported value: +Inf",
+		},
+	}
+	for _, tt := range tests {
+		filename := writeDefinitionFile(t, tt.content)
+		_, err := loadTestDefinitions(filename)
+		defErr, ok := err.(*definitionError)
+		if !ok {
+			t.Errorf("%s: got error %v, want a definition error", tt.name, err)
+			continue
+		}
+		if defErr.file != filename || defErr.line != tt.line || defErr.msg != tt.msg {
+			t.Errorf("%s: got %s:%d: %s, want line %d: %s", tt.name,
+				defErr.file, defErr.line, defErr.msg, tt.line, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DiffFormat selects how baseline mismatches are reported.
+type DiffFormat int
+
+const (
+	// DiffSemantic reports changes to JSON bodies and db baselines
+	// by path, e.g. $.items[3].price: 10 -> 12, and everything else
+	// as a unified diff. This is the default.
+	DiffSemantic DiffFormat = iota
+	// DiffLines reports every mismatch as a unified diff.
+	DiffLines
+)
+
+var jsonPathIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func diffFieldPath(path string, field string) string {
+	if jsonPathIdentifier.MatchString(field) {
+		return path + "." + field
+	}
+	return fmt.Sprintf("%s['%s']", path, strings.ReplaceAll(field, "'", `\'`))
+}
+
+func jsonDiffValue(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// diffJSONValues appends a line for each difference between two
+// decoded JSON values
+func diffJSONValues(path string, expected interface{}, actual interface{}, changes []string) []string {
+	switch ev := expected.(type) {
+	case map[string]interface{}:
+		av, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(ev)+len(av))
+		for k := range ev {
+			keys = append(keys, k)
+		}
+		for k := range av {
+			if _, ok := ev[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			e, inExpected := ev[k]
+			a, inActual := av[k]
+			fieldPath := diffFieldPath(path, k)
+			switch {
+			case !inActual:
+				changes = append(changes, fmt.Sprintf("%s: removed %s", fieldPath, jsonDiffValue(e)))
+			case !inExpected:
+				changes = append(changes, fmt.Sprintf("%s: added %s", fieldPath, jsonDiffValue(a)))
+			default:
+				changes = diffJSONValues(fieldPath, e, a, changes)
+			}
+		}
+		return changes
+	case []interface{}:
+		av, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(ev) || i < len(av); i++ {
+			indexPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(av):
+				changes = append(changes, fmt.Sprintf("%s: removed %s", indexPath, jsonDiffValue(ev[i])))
+			case i >= len(ev):
+				changes = append(changes, fmt.Sprintf("%s: added %s", indexPath, jsonDiffValue(av[i])))
+			default:
+				changes = diffJSONValues(indexPath, ev[i], av[i], changes)
+			}
+		}
+		return changes
+	}
+	e, a := jsonDiffValue(expected), jsonDiffValue(actual)
+	if e != a {
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, e, a))
+	}
+	return changes
+}
+
+func decodeDiffJSON(s string) (interface{}, bool) {
+	d := json.NewDecoder(strings.NewReader(s))
+	// keep numbers as written
+	d.UseNumber()
+	var v interface{}
+	if d.Decode(&v) != nil || d.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+// splitBaselineBody splits a request or response baseline into its
+// headers and body. A db baseline is all body.
+func splitBaselineBody(artifact Artifact, baseline string) (string, string) {
+	if artifact == ArtifactDb {
+		return "", baseline
+	}
+	i := strings.Index(baseline, "\n\n")
+	if i < 0 {
+		return baseline, ""
+	}
+	return baseline[:i+1], baseline[i+2:]
+}
+
+// semanticDiff reports the differences between JSON baselines by
+// path, with a unified diff of any header changes. ok is false if
+// either body is not JSON.
+func (r *httpBaselineTestRunner) semanticDiff(artifact Artifact, expectedPath string,
+	expected string, formatted string) (string, bool) {
+	expectedHead, expectedBody := splitBaselineBody(artifact, expected)
+	actualHead, actualBody := splitBaselineBody(artifact, formatted)
+	ev, ok := decodeDiffJSON(expectedBody)
+	if !ok {
+		return "", false
+	}
+	av, ok := decodeDiffJSON(actualBody)
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(r.baselineDiff(expectedPath, expectedHead, actualHead))
+	changes := diffJSONValues("$", ev, av, nil)
+	if len(changes) > 0 {
+		fmt.Fprintf(&b, "%s (expected) -> actual:\n", expectedPath)
+		for _, change := range changes {
+			b.WriteString(change + "\n")
+		}
+	}
+	return b.String(), true
+}
@@ -0,0 +1,84 @@
+package httpbaselinetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSONValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		changes  []string
+	}{
+		{
+			name:     "equal",
+			expected: `{"a":[1,{"b":true}]}`,
+			actual:   `{"a":[1,{"b":true}]}`,
+			changes:  nil,
+		},
+		{
+			name:     "changed value",
+			expected: `{"items":[{"price":10}]}`,
+			actual:   `{"items":[{"price":12}]}`,
+			changes:  []string{"$.items[0].price: 10 -> 12"},
+		},
+		{
+			name:     "numbers as written",
+			expected: `{"n":1.0}`,
+			actual:   `{"n":1}`,
+			changes:  []string{"$.n: 1.0 -> 1"},
+		},
+		{
+			name:     "added and removed keys in order",
+			expected: `{"b":1,"c":"x"}`,
+			actual:   `{"a":null,"b":1}`,
+			changes:  []string{"$.a: added null", `$.c: removed "x"`},
+		},
+		{
+			name:     "array length",
+			expected: `[1,2]`,
+			actual:   `[1,2,[3]]`,
+			changes:  []string{"$[2]: added [3]"},
+		},
+		{
+			name:     "array shorter",
+			expected: `[1,{"a":1}]`,
+			actual:   `[1]`,
+			changes:  []string{`$[1]: removed {"a":1}`},
+		},
+		{
+			name:     "type change",
+			expected: `{"a":{"b":1}}`,
+			actual:   `{"a":[1]}`,
+			changes:  []string{`$.a: {"b":1} -> [1]`},
+		},
+		{
+			name:     "quoted field",
+			expected: `{"a-b":1,"it's":1}`,
+			actual:   `{"a-b":2,"it's":2}`,
+			changes:  []string{"$['a-b']: 1 -> 2", `$['it\'s']: 1 -> 2`},
+		},
+		{
+			name:     "html not escaped",
+			expected: `{"a":"<b>"}`,
+			actual:   `{"a":"&"}`,
+			changes:  []string{`$.a: "<b>" -> "&"`},
+		},
+	}
+	for _, tt := range tests {
+		expected, ok := decodeDiffJSON(tt.expected)
+		if !ok {
+			t.Fatalf("%s: invalid expected JSON", tt.name)
+		}
+		actual, ok := decodeDiffJSON(tt.actual)
+		if !ok {
+			t.Fatalf("%s: invalid actual JSON", tt.name)
+		}
+		changes := diffJSONValues("$", expected, actual, nil)
+		if !reflect.DeepEqual(changes, tt.changes) {
+			t.Errorf("%s: got %q, want %q", tt.name, changes, tt.changes)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// contentEncodings returns the codings of a Content-Encoding header in
+// the order they were applied, ignoring identity
+func contentEncodings(header http.Header) []string {
+	encodings := []string{}
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	return encodings
+}
+
+// decodeContentEncoding undoes the Content-Encoding of a body so the decoded
+// body can be formatted. Bodies using an unsupported coding are
+// returned as they are, with ok false.
+func decodeContentEncoding(encodings []string, body []byte) (decoded []byte, ok bool, err error) {
+	decoded = body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var r io.Reader
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(bytes.NewReader(decoded))
+			if err != nil {
+				return nil, false, fmt.Errorf("decoding gzip body: %s", err)
+			}
+			r = gr
+		case "deflate":
+			// deflate is meant to be zlib wrapped, but some servers
+			// send raw deflate
+			zr, err := zlib.NewReader(bytes.NewReader(decoded))
+			if err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(decoded))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(decoded))
+		default:
+			return body, false, nil
+		}
+		decoded, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, false, fmt.Errorf("decoding %s body: %s", encodings[i], err)
+		}
+	}
+	return decoded, true, nil
+}
+
+// formatEncodedBody decodes and formats a body, noting the
+// Content-Encoding and compressed size before the decoded body
+func (fo *formatOptions) formatEncodedBody(artifact Artifact, contentType string,
+	header http.Header, body []byte) (string, []byte, error) {
+	encodings := contentEncodings(header)
+	if len(encodings) == 0 {
+		formatted, err := fo.formatBody(artifact, contentType, body)
+		return formatted, body, err
+	}
+	decoded, ok, err := decodeContentEncoding(encodings, body)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		formatted, err := fo.formatBody(artifact, contentType, body)
+		return formatted, body, err
+	}
+	formatted, err := fo.formatBody(artifact, contentType, decoded)
+	if err != nil {
+		return "", nil, err
+	}
+	note := fmt.Sprintf("decoded %s body, %d bytes compressed:\n",
+		strings.Join(encodings, ", "), len(body))
+	return note + formatted, decoded, nil
+}
@@ -0,0 +1,115 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func encodeBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = fw
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	_, err := w.Write(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	body := []byte(`{"make": "Honda", "color": "blue"}`)
+	tests := []struct {
+		name      string
+		applied   []string
+		encodings []string
+	}{
+		{"gzip", []string{"gzip"}, []string{"gzip"}},
+		{"x-gzip", []string{"gzip"}, []string{"x-gzip"}},
+		{"zlib deflate", []string{"deflate"}, []string{"deflate"}},
+		{"raw deflate", []string{"raw deflate"}, []string{"deflate"}},
+		{"br", []string{"br"}, []string{"br"}},
+		{"stacked", []string{"gzip", "br"}, []string{"gzip", "br"}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		encoded := body
+		for _, encoding := range tt.applied {
+			encoded = encodeBody(t, encoding, encoded)
+		}
+		decoded, ok, err := decodeContentEncoding(tt.encodings, encoded)
+		if err != nil || !ok {
+			t.Errorf("%s: decodeContentEncoding = %v, %v", tt.name, ok, err)
+			continue
+		}
+		if !bytes.Equal(decoded, body) {
+			t.Errorf("%s: decoded %q, want %q", tt.name, decoded, body)
+		}
+	}
+}
+
+func TestDecodeContentEncodingUnsupported(t *testing.T) {
+	encoded := encodeBody(t, "gzip", []byte("hello"))
+	for _, encodings := range [][]string{{"compress"}, {"gzip", "zstd"}} {
+		decoded, ok, err := decodeContentEncoding(encodings, encoded)
+		if err != nil || ok || !bytes.Equal(decoded, encoded) {
+			t.Errorf("%v: got %q, %v, %v, want the body unchanged", encodings, decoded, ok, err)
+		}
+	}
+	if _, _, err := decodeContentEncoding([]string{"gzip"}, []byte("not gzip")); err == nil {
+		t.Error("invalid gzip body did not fail")
+	}
+}
+
+func TestContentEncodings(t *testing.T) {
+	header := http.Header{"Content-Encoding": []string{"GZip, identity", " br "}}
+	expected := []string{"gzip", "br"}
+	if got := contentEncodings(header); !reflect.DeepEqual(got, expected) {
+		t.Errorf("contentEncodings = %q, want %q", got, expected)
+	}
+}
+
+func TestFormatEncodedBody(t *testing.T) {
+	body := []byte("hello\n")
+	encoded := encodeBody(t, "gzip", body)
+	header := http.Header{"Content-Encoding": []string{"gzip"}}
+	formatted, decoded, err := (*formatOptions)(nil).formatEncodedBody(ArtifactResponse, "text/plain", header, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, body) {
+		t.Errorf("decoded %q, want %q", decoded, body)
+	}
+	expected := fmt.Sprintf("decoded gzip body, %d bytes compressed:\nhello\n", len(encoded))
+	if formatted != expected {
+		t.Errorf("formatted %q, want %q", formatted, expected)
+	}
+}
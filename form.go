@@ -0,0 +1,128 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormFormatter writes application/x-www-form-urlencoded bodies as
+// decoded fields, one value per line, sorted by field name.
+type FormFormatter struct{}
+
+func (FormFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		for _, value := range values[name] {
+			fmt.Fprintf(&buf, "%s: %s\n", formFieldText(name), formFieldText(value))
+		}
+	}
+	return buf.String(), nil
+}
+
+// formFieldText quotes names and values that would otherwise span
+// lines
+func formFieldText(s string) string {
+	if strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// MultipartFormatter writes multipart/form-data bodies as their
+// fields sorted by name. File parts are written as their file name,
+// content type, size and sha256 instead of their content.
+type MultipartFormatter struct{}
+
+type multipartPart struct {
+	name        string
+	fileName    string
+	contentType string
+	data        []byte
+}
+
+func (MultipartFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	boundary := params["boundary"]
+	if boundary == "" {
+		return "", fmt.Errorf("%s body has no boundary", mediaType)
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []multipartPart
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, multipartPart{
+			name:        p.FormName(),
+			fileName:    p.FileName(),
+			contentType: p.Header.Get("Content-Type"),
+			data:        data,
+		})
+	}
+	// repeated fields keep their order
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].name < parts[j].name
+	})
+	var buf bytes.Buffer
+	for _, p := range parts {
+		if p.fileName == "" {
+			fmt.Fprintf(&buf, "field %q:\n", p.name)
+			for _, line := range strings.Split(string(p.data), "\n") {
+				buf.WriteString("  " + strings.TrimSuffix(line, "\r") + "\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&buf, "file %q:\n", p.name)
+		fmt.Fprintf(&buf, "  filename: %s\n", p.fileName)
+		if p.contentType != "" {
+			fmt.Fprintf(&buf, "  content-type: %s\n", p.contentType)
+		}
+		fmt.Fprintf(&buf, "  size: %d\n", len(p.data))
+		fmt.Fprintf(&buf, "  sha256: %x\n", sha256.Sum256(p.data))
+	}
+	return buf.String(), nil
+}
+
+var multipartBoundaryRegexp = regexp.MustCompile(`(?i)(;\s*boundary=)("[^"]*"|[^;\s]+)`)
+
+// normalizeContentType replaces the random boundary of multipart
+// content types so baselines don't change on every run
+func normalizeContentType(value string) string {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "multipart/") {
+		return value
+	}
+	return multipartBoundaryRegexp.ReplaceAllString(value, "${1}<boundary>")
+}
+
+func normalizeContentTypes(values []string) []string {
+	normalized := make([]string, len(values))
+	for i, value := range values {
+		normalized[i] = normalizeContentType(value)
+	}
+	return normalized
+}
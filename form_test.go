@@ -0,0 +1,128 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+func TestFormFormatter(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{"", ""},
+		{"b=2&a=1&b=1", "a: 1\nb: 2\nb: 1\n"},
+		{"name=Honda+Civic&note=a%0Ab", "name: Honda Civic\nnote: \"a\\nb\"\n"},
+	}
+	for _, tt := range tests {
+		got, err := FormFormatter{}.FormatBody("application/x-www-form-urlencoded", nil, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%q failed: %s", tt.body, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%q = %q, want %q", tt.body, got, tt.expected)
+		}
+	}
+	if _, err := (FormFormatter{}).FormatBody("application/x-www-form-urlencoded", nil, []byte("a=%zz")); err == nil {
+		t.Error("invalid escape did not fail")
+	}
+}
+
+// buildMultipart writes the same fields in the same order with the
+// given boundary
+func buildMultipart(t *testing.T, boundary string) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	err := w.SetBoundary(boundary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := [][2]string{{"make", "Honda"}, {"tag", "red"}, {"color", "blue\r\nnavy"}, {"tag", "fast"}}
+	for _, field := range fields {
+		err = w.WriteField(field[0], field[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="photo"; filename="car.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = part.Write([]byte("png data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.FormDataContentType(), buf.Bytes()
+}
+
+func TestMultipartFormatter(t *testing.T) {
+	expected := `field "color":
+  blue
+  navy
+field "make":
+  Honda
+file "photo":
+  filename: car.png
+  content-type: image/png
+  size: 8
+  sha256: e12b061e0cc3b3e287c561a9075dc9562c704a4674615b78bb770fe97810ba68
+field "tag":
+  red
+field "tag":
+  fast
+`
+	var outputs []string
+	for _, boundary := range []string{"a1b2c3", "9f8e7d6c5b4a39281706"} {
+		contentType, body := buildMultipart(t, boundary)
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := MultipartFormatter{}.FormatBody(mediaType, params, body)
+		if err != nil {
+			t.Fatalf("boundary %s: %s", boundary, err)
+		}
+		outputs = append(outputs, got)
+		if normalized := normalizeContentType(contentType); normalized != "multipart/form-data; boundary=<boundary>" {
+			t.Errorf("normalizeContentType(%q) = %q", contentType, normalized)
+		}
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("output depends on the boundary:\n%s\n%s", outputs[0], outputs[1])
+	}
+	if outputs[0] != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", outputs[0], expected)
+	}
+	if _, err := (MultipartFormatter{}).FormatBody("multipart/form-data", nil, nil); err == nil {
+		t.Error("missing boundary did not fail")
+	}
+}
+
+func TestNormalizeContentType(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"application/json", "application/json"},
+		{"text/plain; boundary=abc", "text/plain; boundary=abc"},
+		{"multipart/form-data; boundary=abc", "multipart/form-data; boundary=<boundary>"},
+		{`Multipart/Mixed; BOUNDARY="a b"; charset=utf-8`, "Multipart/Mixed; BOUNDARY=<boundary>; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		if got := normalizeContentType(tt.value); got != tt.expected {
+			t.Errorf("normalizeContentType(%q) = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
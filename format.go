@@ -0,0 +1,268 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// jsonTransform rewrites a decoded JSON value before it is formatted
+type jsonTransform func(v interface{}) (interface{}, error)
+
+// formatOptions holds the per test settings used to format
+// baselines. A nil *formatOptions formats without changes.
+type formatOptions struct {
+	scrubbers   []compiledScrubber
+	arraySorts  []compiledArraySort
+	headerRules []HeaderRule
+	formatters  *FormatterRegistry
+	// binary bodies formatted since the last checkBaseline
+	binaryBodies map[Artifact]*binaryBody
+	// nil unless UUID aliasing is on
+	uuidAliases *uuidAliaser
+	// whether UUIDs from seed data keep their value
+	preserveSeedUUIDs bool
+}
+
+func newFormatOptions(suite *Suite, btest *HTTPBaselineTest) (*formatOptions, error) {
+	scrubbers := append(append([]Scrubber{}, suite.opts.scrubbers...), btest.Scrubbers...)
+	compiled, err := compileScrubbers(scrubbers)
+	if err != nil {
+		return nil, err
+	}
+	arraySorts := append(append([]ArraySort{}, suite.opts.arraySorts...), btest.ArraySorts...)
+	compiledSorts, err := compileArraySorts(arraySorts)
+	if err != nil {
+		return nil, err
+	}
+	headerRules := append(append([]HeaderRule{}, suite.opts.headerRules...), btest.HeaderRules...)
+	compiledRules, err := compileHeaderRules(headerRules)
+	if err != nil {
+		return nil, err
+	}
+	fo := &formatOptions{
+		scrubbers:    compiled,
+		arraySorts:   compiledSorts,
+		headerRules:  compiledRules,
+		formatters:   suite.opts.formatters,
+		binaryBodies: make(map[Artifact]*binaryBody),
+	}
+	if suite.opts.uuidAliasing != UUIDAliasingOff {
+		fo.uuidAliases = newUUIDAliaser()
+		fo.preserveSeedUUIDs = suite.opts.uuidAliasing == UUIDAliasingExceptSeed
+	}
+	return fo, nil
+}
+
+// headerValues applies the header rules to the values of a header.
+// A header that was dropped has no values.
+func (fo *formatOptions) headerValues(artifact Artifact, name string, values []string) []string {
+	if fo == nil {
+		return values
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		keep := true
+		for i := range fo.headerRules {
+			if keep && fo.headerRules[i].appliesTo(artifact, name) {
+				value, keep = fo.headerRules[i].apply(value)
+			}
+		}
+		if keep {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// aliasUUIDs replaces UUIDs in a formatted baseline if UUID aliasing
+// is on
+func (fo *formatOptions) aliasUUIDs(formatted string) string {
+	if fo == nil || fo.uuidAliases == nil {
+		return formatted
+	}
+	return fo.uuidAliases.alias(formatted)
+}
+
+// unescapePlaceholders undoes the HTML escaping of scrubber
+// placeholders in formatted JSON, so "\u003ctimestamp\u003e" is
+// written as "<timestamp>". Other values keep their escaping so
+// existing baselines don't change.
+func (fo *formatOptions) unescapePlaceholders(formatted string) string {
+	if fo == nil {
+		return formatted
+	}
+	for i := range fo.scrubbers {
+		placeholder := fo.scrubbers[i].scrubber.Placeholder
+		escaped, err := json.Marshal(placeholder)
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(placeholder)
+		if err != nil {
+			continue
+		}
+		unescaped := strings.TrimSuffix(buf.String(), "\n")
+		if string(escaped) != unescaped {
+			formatted = strings.ReplaceAll(formatted, string(escaped), unescaped)
+		}
+	}
+	return formatted
+}
+
+// seedData records data loaded before the test so its UUIDs can be
+// left as they are
+func (fo *formatOptions) seedData(data string) {
+	if fo == nil || !fo.preserveSeedUUIDs {
+		return
+	}
+	fo.uuidAliases.preserve(data)
+}
+
+// jsonTransform returns the transform for JSON in an artifact, or
+// nil if there is nothing to do
+func (fo *formatOptions) jsonTransform(artifact Artifact) jsonTransform {
+	if fo == nil {
+		return nil
+	}
+	transforms := []jsonTransform{}
+	// scrub first so arrays can be sorted by scrubbed values
+	for i := range fo.scrubbers {
+		if artifactSelected(fo.scrubbers[i].scrubber.Artifacts, artifact) {
+			transforms = append(transforms, fo.scrubbers[i].scrub)
+		}
+	}
+	for i := range fo.arraySorts {
+		if artifactSelected(fo.arraySorts[i].arraySort.Artifacts, artifact) {
+			transforms = append(transforms, fo.arraySorts[i].sort)
+		}
+	}
+	if len(transforms) == 0 {
+		return nil
+	}
+	return func(v interface{}) (interface{}, error) {
+		var err error
+		for _, transform := range transforms {
+			v, err = transform(v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+}
+
+// lookupFormatter returns the formatter registered for a content type
+func (fo *formatOptions) lookupFormatter(contentType string) (BodyFormatter, string, map[string]string) {
+	formatters := defaultFormatters
+	if fo != nil && fo.formatters != nil {
+		formatters = fo.formatters
+	}
+	return formatters.Lookup(contentType)
+}
+
+// formatBody formats a body with the formatter registered for its
+// content type. JSON bodies are scrubbed and sorted first. Binary
+// bodies without a formatter are written as their size and hash, and
+// kept for their sidecar file; other bodies are written as they are.
+func (fo *formatOptions) formatBody(artifact Artifact, contentType string, body []byte) (string, error) {
+	formatter, mediaType, params := fo.lookupFormatter(contentType)
+	transform := fo.jsonTransform(artifact)
+	if tf, ok := formatter.(transformingFormatter); ok && transform != nil {
+		return tf.formatBodyTransformed(mediaType, params, body, transform)
+	}
+	if transform != nil && isJSONMediaType(mediaType) {
+		var v interface{}
+		err := json.Unmarshal(body, &v)
+		if err != nil {
+			return "", err
+		}
+		v, err = transform(v)
+		if err != nil {
+			return "", err
+		}
+		body, err = json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+	}
+	if formatter == nil {
+		if !isBinaryBody(mediaType, body) {
+			return string(body), nil
+		}
+		if fo != nil {
+			fo.binaryBodies[artifact] = &binaryBody{
+				ext:  binaryExtension(mediaType, body),
+				data: body,
+			}
+		}
+		return formatBinaryBody(body), nil
+	}
+	return formatter.FormatBody(mediaType, params, body)
+}
+
+// takeBinaryBody returns and forgets the binary body of an artifact,
+// if it had one
+func (fo *formatOptions) takeBinaryBody(artifact Artifact) *binaryBody {
+	if fo == nil {
+		return nil
+	}
+	binary := fo.binaryBodies[artifact]
+	delete(fo.binaryBodies, artifact)
+	return binary
+}
+
+// rowSortKey returns the text db rows are ordered by. With UUID
+// aliasing on, UUIDs are masked since the aliases only depend on the
+// order they appear in.
+func (fo *formatOptions) rowSortKey(row interface{}) (string, error) {
+	key, err := json.Marshal(row)
+	if err != nil {
+		return "", err
+	}
+	if fo == nil || fo.uuidAliases == nil {
+		return string(key), nil
+	}
+	return fo.uuidAliases.mask(string(key)), nil
+}
+
+// transformRows applies a transform to each db row, then re-sorts
+// the rows since scrubbed values and UUID aliases may change their
+// order
+func (fo *formatOptions) transformRows(rows []interface{}, transform jsonTransform) ([]interface{}, error) {
+	if transform == nil && (fo == nil || fo.uuidAliases == nil) {
+		return rows, nil
+	}
+	keyed := make([]struct {
+		key string
+		row interface{}
+	}, len(rows))
+	for i := range rows {
+		row := rows[i]
+		if transform != nil {
+			var err error
+			row, err = transform(row)
+			if err != nil {
+				return nil, err
+			}
+		}
+		key, err := fo.rowSortKey(row)
+		if err != nil {
+			return nil, err
+		}
+		keyed[i].key = key
+		keyed[i].row = row
+	}
+	sort.SliceStable(keyed, func(i, j int) bool {
+		return keyed[i].key < keyed[j].key
+	})
+	transformed := make([]interface{}, len(rows))
+	for i := range keyed {
+		transformed[i] = keyed[i].row
+	}
+	return transformed, nil
+}
@@ -0,0 +1,30 @@
+package httpbaselinetest
+
+import (
+	"testing"
+)
+
+func TestUnescapePlaceholders(t *testing.T) {
+	fo := &formatOptions{scrubbers: []compiledScrubber{
+		{scrubber: ScrubTimestamp("$.at")},
+		{scrubber: ScrubValue("$.id", "id")},
+	}}
+	tests := []struct {
+		formatted string
+		expected  string
+	}{
+		{`{"at": "\u003ctimestamp\u003e"}`, `{"at": "<timestamp>"}`},
+		{`{"url": "/cars?a=1\u0026b=2"}`, `{"url": "/cars?a=1\u0026b=2"}`},
+		{`{"s": "\u003ctimestamp\u003e later"}`, `{"s": "\u003ctimestamp\u003e later"}`},
+		{`{"id": "id"}`, `{"id": "id"}`},
+	}
+	for _, tt := range tests {
+		if got := fo.unescapePlaceholders(tt.formatted); got != tt.expected {
+			t.Errorf("unescapePlaceholders(%s) = %s, want %s", tt.formatted, got, tt.expected)
+		}
+	}
+	var nilOptions *formatOptions
+	if got := nilOptions.unescapePlaceholders(`"<"`); got != `"<"` {
+		t.Errorf("nil options changed %s", got)
+	}
+}
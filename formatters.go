@@ -0,0 +1,104 @@
+package httpbaselinetest
+
+import (
+	"mime"
+	"strings"
+)
+
+// BodyFormatter formats a request or response body for a baseline.
+// mediaType is the lower case media type of the Content-Type header
+// and params are its parameters, e.g. charset.
+type BodyFormatter interface {
+	FormatBody(mediaType string, params map[string]string, body []byte) (string, error)
+}
+
+// BodyFormatterFunc adapts a function to a BodyFormatter.
+type BodyFormatterFunc func(mediaType string, params map[string]string, body []byte) (string, error)
+
+func (f BodyFormatterFunc) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	return f(mediaType, params, body)
+}
+
+// JSONFormatter pretty prints JSON bodies with sorted keys.
+type JSONFormatter struct{}
+
+func (JSONFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	return formatJSON(body, nil)
+}
+
+var defaultFormatters = NewFormatterRegistry()
+
+// FormatterRegistry maps media types to the BodyFormatter used for
+// them. Keys are full media types like "application/json",
+// structured syntax suffixes like "+json", or wildcards like
+// "text/*".
+type FormatterRegistry struct {
+	formatters map[string]BodyFormatter
+}
+
+// NewFormatterRegistry returns a registry with the built in
+// formatters.
+func NewFormatterRegistry() *FormatterRegistry {
+	fr := &FormatterRegistry{
+		formatters: make(map[string]BodyFormatter),
+	}
+	fr.Register("application/json", JSONFormatter{})
+	fr.Register("+json", JSONFormatter{})
+	fr.registerXML(XMLFormatter{})
+	fr.Register("application/x-www-form-urlencoded", FormFormatter{})
+	fr.Register("multipart/form-data", MultipartFormatter{})
+	fr.Register("application/x-ndjson", NDJSONFormatter{})
+	fr.Register("application/ndjson", NDJSONFormatter{})
+	fr.Register("text/event-stream", SSEFormatter{})
+	return fr
+}
+
+// Register sets the formatter for a media type, suffix or wildcard,
+// replacing any existing one.
+func (fr *FormatterRegistry) Register(mediaType string, formatter BodyFormatter) {
+	fr.formatters[strings.ToLower(mediaType)] = formatter
+}
+
+// Lookup returns the formatter for a Content-Type header value, with
+// the parsed media type and parameters. Exact media types are
+// preferred over suffixes, and suffixes over wildcards.
+func (fr *FormatterRegistry) Lookup(contentType string) (BodyFormatter, string, map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", nil
+	}
+	if f, ok := fr.formatters[mediaType]; ok {
+		return f, mediaType, params
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		if f, ok := fr.formatters[mediaType[i:]]; ok {
+			return f, mediaType, params
+		}
+	}
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		if f, ok := fr.formatters[mediaType[:i]+"/*"]; ok {
+			return f, mediaType, params
+		}
+	}
+	return nil, mediaType, params
+}
+
+func (fr *FormatterRegistry) registerXML(formatter XMLFormatter) {
+	for _, mediaType := range []string{"application/xml", "text/xml", "+xml"} {
+		fr.Register(mediaType, formatter)
+	}
+}
+
+func (fr *FormatterRegistry) clone() *FormatterRegistry {
+	c := &FormatterRegistry{
+		formatters: make(map[string]BodyFormatter),
+	}
+	for k, v := range fr.formatters {
+		c.formatters[k] = v
+	}
+	return c
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,59 @@
+package httpbaselinetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatterRegistryLookup(t *testing.T) {
+	csv := BodyFormatterFunc(func(mediaType string, params map[string]string, body []byte) (string, error) {
+		return "csv", nil
+	})
+	text := BodyFormatterFunc(func(mediaType string, params map[string]string, body []byte) (string, error) {
+		return "text", nil
+	})
+	fr := NewFormatterRegistry()
+	fr.Register("TEXT/*", text)
+	fr.Register("text/csv", csv)
+	tests := []struct {
+		contentType string
+		formatter   string
+		mediaType   string
+		params      map[string]string
+	}{
+		{"application/json", "json", "application/json", map[string]string{}},
+		{"application/problem+json", "json", "application/problem+json", map[string]string{}},
+		{"TEXT/XML; charset=ISO-8859-1", "xml", "text/xml", map[string]string{"charset": "ISO-8859-1"}},
+		{"application/soap+xml; charset=utf-8", "xml", "application/soap+xml",
+			map[string]string{"charset": "utf-8"}},
+		// an exact match beats the wildcard
+		{"text/csv", "csv", "text/csv", map[string]string{}},
+		{"text/plain; charset=utf-8", "text", "text/plain", map[string]string{"charset": "utf-8"}},
+		// suffixes beat wildcards
+		{"text/vnd.custom+json", "json", "text/vnd.custom+json", map[string]string{}},
+		{"image/png", "", "image/png", map[string]string{}},
+		{"not a media type", "", "", nil},
+		{"", "", "", nil},
+	}
+	for _, tt := range tests {
+		f, mediaType, params := fr.Lookup(tt.contentType)
+		name := ""
+		switch tf := f.(type) {
+		case JSONFormatter:
+			name = "json"
+		case XMLFormatter:
+			name = "xml"
+		case BodyFormatterFunc:
+			name, _ = tf("", nil, nil)
+		}
+		if name != tt.formatter {
+			t.Errorf("%q: got formatter %q, want %q", tt.contentType, name, tt.formatter)
+		}
+		if mediaType != tt.mediaType {
+			t.Errorf("%q: got media type %q, want %q", tt.contentType, mediaType, tt.mediaType)
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%q: got params %v, want %v", tt.contentType, params, tt.params)
+		}
+	}
+}
@@ -3,10 +3,12 @@ module github.com/trussworks/httpbaselinetest
 go 1.18
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/romanyx/polluter v1.2.2
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
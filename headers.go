@@ -0,0 +1,111 @@
+package httpbaselinetest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// HeaderAction is what a HeaderRule does to a matching header.
+type HeaderAction int
+
+const (
+	// HeaderDrop leaves the header out of the baseline
+	HeaderDrop HeaderAction = iota
+	// HeaderMask keeps the header name but replaces its value
+	HeaderMask
+	// HeaderReplace replaces the parts of the value matching
+	// Pattern
+	HeaderReplace
+)
+
+// HeaderRule changes how a request or response header is written to
+// baselines, e.g. to drop Date headers or mask Authorization
+// secrets.
+type HeaderRule struct {
+	// Name is the header name, matched case insensitively
+	Name   string
+	Action HeaderAction
+	// Pattern is the regular expression replaced by HeaderReplace
+	Pattern *regexp.Regexp
+	// Replacement is the new value for HeaderMask, "<masked>" if
+	// empty, or the regexp replacement for HeaderReplace
+	Replacement string
+	// Artifacts limits the rule to request or response
+	// baselines. Empty means both.
+	Artifacts []Artifact
+
+	// the pattern given to ReplaceHeader, compiled by
+	// compileHeaderRules so a bad pattern fails the test
+	pattern string
+}
+
+// DropHeader leaves the header out of baselines
+func DropHeader(name string) HeaderRule {
+	return HeaderRule{
+		Name:   name,
+		Action: HeaderDrop,
+	}
+}
+
+// MaskHeader replaces the header's value with <masked>
+func MaskHeader(name string) HeaderRule {
+	return HeaderRule{
+		Name:   name,
+		Action: HeaderMask,
+	}
+}
+
+// ReplaceHeader replaces matches of pattern in the header's value,
+// e.g. ReplaceHeader("Set-Cookie", `session=[^;]*`, "session=<session>").
+// An invalid pattern fails the tests using the rule.
+func ReplaceHeader(name string, pattern string, replacement string) HeaderRule {
+	return HeaderRule{
+		Name:        name,
+		Action:      HeaderReplace,
+		Replacement: replacement,
+		pattern:     pattern,
+	}
+}
+
+func compileHeaderRules(rules []HeaderRule) ([]HeaderRule, error) {
+	compiled := make([]HeaderRule, len(rules))
+	for i, rule := range rules {
+		if rule.Pattern == nil && rule.pattern != "" {
+			p, err := regexp.Compile(rule.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for header %s: %s", rule.Name, err)
+			}
+			rule.Pattern = p
+		}
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+func (hr *HeaderRule) appliesTo(artifact Artifact, name string) bool {
+	if http.CanonicalHeaderKey(hr.Name) != http.CanonicalHeaderKey(name) {
+		return false
+	}
+	return artifactSelected(hr.Artifacts, artifact)
+}
+
+// apply returns the new header value, or false if the header should
+// be dropped
+func (hr *HeaderRule) apply(value string) (string, bool) {
+	switch hr.Action {
+	case HeaderDrop:
+		return "", false
+	case HeaderMask:
+		if hr.Replacement == "" {
+			return "<masked>", true
+		}
+		return hr.Replacement, true
+	case HeaderReplace:
+		if hr.Pattern == nil {
+			return value, true
+		}
+		return hr.Pattern.ReplaceAllString(value, hr.Replacement), true
+	}
+	return value, true
+}
@@ -0,0 +1,89 @@
+package httpbaselinetest
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaderValues(t *testing.T) {
+	responseOnly := MaskHeader("X-Token")
+	responseOnly.Artifacts = []Artifact{ArtifactResponse}
+	rules, err := compileHeaderRules([]HeaderRule{
+		DropHeader("date"),
+		MaskHeader("Authorization"),
+		{Name: "X-Api-Key", Action: HeaderMask, Replacement: "<key>"},
+		ReplaceHeader("Set-Cookie", `session=[^;]*`, "session=<session>"),
+		responseOnly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fo := &formatOptions{headerRules: rules}
+	tests := []struct {
+		artifact Artifact
+		name     string
+		values   []string
+		expected []string
+	}{
+		{ArtifactResponse, "Date", []string{"Mon, 02 Jan 2006"}, []string{}},
+		{ArtifactRequest, "Authorization", []string{"Bearer secret"}, []string{"<masked>"}},
+		{ArtifactRequest, "x-api-key", []string{"abc"}, []string{"<key>"}},
+		{ArtifactResponse, "Set-Cookie", []string{"session=abc; Path=/", "theme=dark"},
+			[]string{"session=<session>; Path=/", "theme=dark"}},
+		{ArtifactResponse, "X-Token", []string{"abc"}, []string{"<masked>"}},
+		{ArtifactRequest, "X-Token", []string{"abc"}, []string{"abc"}},
+		{ArtifactRequest, "Accept", []string{"*/*"}, []string{"*/*"}},
+	}
+	for _, tt := range tests {
+		got := fo.headerValues(tt.artifact, tt.name, tt.values)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s %s = %q, want %q", tt.artifact, tt.name, got, tt.expected)
+		}
+	}
+	var nilOptions *formatOptions
+	if got := nilOptions.headerValues(ArtifactRequest, "Date", []string{"x"}); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("nil options changed the header: %q", got)
+	}
+}
+
+func TestHeaderRulesHost(t *testing.T) {
+	for _, tt := range []struct {
+		rule     HeaderRule
+		expected string
+	}{
+		{MaskHeader("host"), "Host: <masked>\n"},
+		{DropHeader("Host"), ""},
+	} {
+		rules, err := compileHeaderRules([]HeaderRule{tt.rule})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/cars", nil)
+		formatted, _, err := formatRequest(req, &formatOptions{headerRules: rules})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "GET /cars HTTP/1.1\n" + tt.expected
+		hosts := strings.Count(tt.expected, "Host:")
+		if !strings.HasPrefix(formatted, expected) || strings.Count(formatted, "Host:") != hosts {
+			t.Errorf("got %q, want it to start with %q", formatted, expected)
+		}
+	}
+}
+
+func TestReplaceHeaderInvalidPattern(t *testing.T) {
+	_, err := compileHeaderRules([]HeaderRule{ReplaceHeader("Set-Cookie", `session=(`, "")})
+	if err == nil {
+		t.Fatal("invalid pattern did not fail")
+	}
+	if !strings.Contains(err.Error(), "Set-Cookie") {
+		t.Errorf("error %q does not name the header", err)
+	}
+	suite := &Suite{opts: defaultSuiteOptions()}
+	btest := &HTTPBaselineTest{HeaderRules: []HeaderRule{ReplaceHeader("Set-Cookie", `(`, "")}}
+	if _, err := newFormatOptions(suite, btest); err == nil {
+		t.Error("newFormatOptions accepted an invalid pattern")
+	}
+}
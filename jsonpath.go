@@ -0,0 +1,173 @@
+package httpbaselinetest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonPathStepKind int
+
+const (
+	jsonPathField jsonPathStepKind = iota
+	jsonPathIndex
+	jsonPathWildcard
+	// jsonPathDescend matches the following step at any depth
+	jsonPathDescend
+)
+
+type jsonPathStep struct {
+	kind  jsonPathStepKind
+	field string
+	index int
+}
+
+// jsonPath is a parsed subset of JSONPath: $, .field, ['field'],
+// [index], [*], .* and ..field
+type jsonPath struct {
+	expr  string
+	steps []jsonPathStep
+}
+
+func parseJSONPath(expr string) (*jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSON path %q must start with $", expr)
+	}
+	p := &jsonPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			p.steps = append(p.steps, jsonPathStep{kind: jsonPathDescend})
+			rest = rest[1:]
+			if strings.HasPrefix(rest, ".[") {
+				rest = rest[1:]
+			}
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return nil, fmt.Errorf("JSON path %q has an empty field name", expr)
+			}
+			if field == "*" {
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathWildcard})
+			} else {
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathField, field: field})
+			}
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSON path %q is missing ]", expr)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathWildcard})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') &&
+				selector[len(selector)-1] == selector[0]:
+				p.steps = append(p.steps, jsonPathStep{
+					kind:  jsonPathField,
+					field: selector[1 : len(selector)-1],
+				})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("JSON path %q has invalid selector [%s]", expr, selector)
+				}
+				p.steps = append(p.steps, jsonPathStep{kind: jsonPathIndex, index: index})
+			}
+		default:
+			return nil, fmt.Errorf("JSON path %q is invalid at %q", expr, rest)
+		}
+	}
+	if len(p.steps) > 0 && p.steps[len(p.steps)-1].kind == jsonPathDescend {
+		return nil, fmt.Errorf("JSON path %q cannot end with ..", expr)
+	}
+	return p, nil
+}
+
+func (p *jsonPath) String() string {
+	return p.expr
+}
+
+// replace calls fn for every value in v matched by the path and
+// replaces the value with the result. Matching the root replaces v
+// itself, so the possibly new root is returned.
+func (p *jsonPath) replace(v interface{}, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	return replaceJSONPathSteps(v, p.steps, fn)
+}
+
+func replaceJSONPathSteps(v interface{}, steps []jsonPathStep,
+	fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(steps) == 0 {
+		return fn(v)
+	}
+	step, rest := steps[0], steps[1:]
+	var err error
+	switch step.kind {
+	case jsonPathField:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		fv, ok := m[step.field]
+		if !ok {
+			return v, nil
+		}
+		m[step.field], err = replaceJSONPathSteps(fv, rest, fn)
+	case jsonPathIndex:
+		l, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		index := step.index
+		if index < 0 {
+			index += len(l)
+		}
+		if index < 0 || index >= len(l) {
+			return v, nil
+		}
+		l[index], err = replaceJSONPathSteps(l[index], rest, fn)
+	case jsonPathWildcard:
+		err = replaceJSONChildren(v, func(child interface{}) (interface{}, error) {
+			return replaceJSONPathSteps(child, rest, fn)
+		})
+	case jsonPathDescend:
+		// match the rest of the path here, then at every level
+		// below
+		v, err = replaceJSONPathSteps(v, rest, fn)
+		if err != nil {
+			return v, err
+		}
+		err = replaceJSONChildren(v, func(child interface{}) (interface{}, error) {
+			return replaceJSONPathSteps(child, steps, fn)
+		})
+	}
+	return v, err
+}
+
+func replaceJSONChildren(v interface{}, fn func(interface{}) (interface{}, error)) error {
+	var err error
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k := range tv {
+			tv[k], err = fn(tv[k])
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i := range tv {
+			tv[i], err = fn(tv[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
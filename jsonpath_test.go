@@ -0,0 +1,107 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		expr  string
+		steps []jsonPathStep
+	}{
+		{"$", nil},
+		{"$.a", []jsonPathStep{{kind: jsonPathField, field: "a"}}},
+		{"$.a.b", []jsonPathStep{
+			{kind: jsonPathField, field: "a"},
+			{kind: jsonPathField, field: "b"},
+		}},
+		{"$['a.b']", []jsonPathStep{{kind: jsonPathField, field: "a.b"}}},
+		{`$["a"]`, []jsonPathStep{{kind: jsonPathField, field: "a"}}},
+		{"$.a[2]", []jsonPathStep{
+			{kind: jsonPathField, field: "a"},
+			{kind: jsonPathIndex, index: 2},
+		}},
+		{"$[-1]", []jsonPathStep{{kind: jsonPathIndex, index: -1}}},
+		{"$.*", []jsonPathStep{{kind: jsonPathWildcard}}},
+		{"$.a[*].b", []jsonPathStep{
+			{kind: jsonPathField, field: "a"},
+			{kind: jsonPathWildcard},
+			{kind: jsonPathField, field: "b"},
+		}},
+		{"$..id", []jsonPathStep{
+			{kind: jsonPathDescend},
+			{kind: jsonPathField, field: "id"},
+		}},
+		{"$..[0]", []jsonPathStep{
+			{kind: jsonPathDescend},
+			{kind: jsonPathIndex, index: 0},
+		}},
+	}
+	for _, tt := range tests {
+		p, err := parseJSONPath(tt.expr)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q) failed: %s", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.steps, tt.steps) {
+			t.Errorf("parseJSONPath(%q) = %+v, want %+v", tt.expr, p.steps, tt.steps)
+		}
+		if p.String() != tt.expr {
+			t.Errorf("parseJSONPath(%q).String() = %q", tt.expr, p.String())
+		}
+	}
+	for _, expr := range []string{"", "a", "$.", "$.a.", "$[0", "$[x]", "$..", "$a"} {
+		if _, err := parseJSONPath(expr); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestJSONPathReplace(t *testing.T) {
+	tests := []struct {
+		expr     string
+		input    string
+		expected string
+	}{
+		{"$", `{"a":1}`, `"x"`},
+		{"$.a", `{"a":1,"b":2}`, `{"a":"x","b":2}`},
+		{"$.missing", `{"a":1}`, `{"a":1}`},
+		{"$.a.b", `{"a":[1]}`, `{"a":[1]}`},
+		{"$['a.b']", `{"a.b":1}`, `{"a.b":"x"}`},
+		{"$[1]", `[1,2,3]`, `[1,"x",3]`},
+		{"$[-1]", `[1,2,3]`, `[1,2,"x"]`},
+		{"$[3]", `[1,2,3]`, `[1,2,3]`},
+		{"$.*", `{"a":1,"b":2}`, `{"a":"x","b":"x"}`},
+		{"$[*].id", `[{"id":1},{"id":2},{"n":3}]`, `[{"id":"x"},{"id":"x"},{"n":3}]`},
+		{"$..id", `{"id":1,"c":[{"id":2,"d":{"id":3}}]}`,
+			`{"c":[{"d":{"id":"x"},"id":"x"}],"id":"x"}`},
+		{"$..[0]", `{"a":[[1,2],3]}`, `{"a":["x",3]}`},
+	}
+	for _, tt := range tests {
+		p, err := parseJSONPath(tt.expr)
+		if err != nil {
+			t.Fatalf("parseJSONPath(%q) failed: %s", tt.expr, err)
+		}
+		var v interface{}
+		err = json.Unmarshal([]byte(tt.input), &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err = p.replace(v, func(interface{}) (interface{}, error) {
+			return "x", nil
+		})
+		if err != nil {
+			t.Errorf("%s on %s failed: %s", tt.expr, tt.input, err)
+			continue
+		}
+		got, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.expected {
+			t.Errorf("%s on %s = %s, want %s", tt.expr, tt.input, got, tt.expected)
+		}
+	}
+}
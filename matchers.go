@@ -0,0 +1,183 @@
+package httpbaselinetest
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Baselines may contain matchers that are evaluated when comparing
+// instead of requiring an exact match:
+//
+//	{{any}}          any text
+//	{{uuid}}         a UUID
+//	{{rfc3339}}      an RFC3339 timestamp
+//	{{regex:[0-9]+}} text matching a regular expression
+//
+// Matchers only match within a single line.
+var matcherStartRegexp = regexp.MustCompile(`\{\{\s*(any|uuid|rfc3339|regex:)`)
+
+var matcherEndRegexp = regexp.MustCompile(`^\s*\}\}`)
+
+var matcherPatterns = map[string]string{
+	"any":     `.*`,
+	"uuid":    `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"rfc3339": `\d{4}-\d{2}-\d{2}[Tt]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:[Zz]|[+-]\d{2}:\d{2})`,
+}
+
+type matcherLoc struct {
+	start   int
+	end     int
+	pattern string
+}
+
+// findMatchers returns the matchers in a line
+func findMatchers(line string) []matcherLoc {
+	var matchers []matcherLoc
+	offset := 0
+	for {
+		loc := matcherStartRegexp.FindStringSubmatchIndex(line[offset:])
+		if loc == nil {
+			return matchers
+		}
+		start, rest := offset+loc[0], offset+loc[1]
+		name := line[offset+loc[2] : offset+loc[3]]
+		offset = rest
+		if name != "regex:" {
+			end := matcherEndRegexp.FindStringIndex(line[rest:])
+			if end == nil {
+				continue
+			}
+			offset = rest + end[1]
+			matchers = append(matchers, matcherLoc{start, offset, matcherPatterns[name]})
+			continue
+		}
+		n := regexMatcherLen(line[rest:])
+		if n < 0 {
+			continue
+		}
+		end := matcherEndRegexp.FindStringIndex(line[rest+n:])
+		offset = rest + n + end[1]
+		matchers = append(matchers, matcherLoc{start, offset, line[rest : rest+n]})
+	}
+}
+
+// regexMatcherLen returns the length of the regex at the start of s,
+// which ends at the first }} outside of braces, brackets and escapes
+// so quantifiers like {2} are part of it. It returns -1 if the regex
+// is not closed.
+func regexMatcherLen(s string) int {
+	depth := 0
+	inClass := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case inClass:
+			inClass = s[i] != ']'
+		case s[i] == '[':
+			inClass = true
+		case s[i] == '{':
+			depth++
+		case depth > 0 && s[i] == '}':
+			depth--
+		case matcherEndRegexp.MatchString(s[i:]):
+			return i
+		}
+	}
+	return -1
+}
+
+// compileMatcherLine returns a regexp for a baseline line containing
+// matchers, or nil if the line has none or an invalid regex matcher.
+// A trailing comma is optional so a JSON line still matches when a
+// key is added or removed after it.
+func compileMatcherLine(line string) *regexp.Regexp {
+	line, _ = splitTrailingComma(line)
+	matchers := findMatchers(line)
+	if len(matchers) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, m := range matchers {
+		b.WriteString(regexp.QuoteMeta(line[last:m.start]))
+		b.WriteString("(?:" + m.pattern + ")")
+		last = m.end
+	}
+	b.WriteString(regexp.QuoteMeta(line[last:]))
+	b.WriteString(",?$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// splitTrailingComma removes a trailing comma from a line, and
+// reports whether it had one
+func splitTrailingComma(line string) (string, bool) {
+	if strings.HasSuffix(line, ",") {
+		return line[:len(line)-1], true
+	}
+	return line, false
+}
+
+// withCommaOf gives line, which may end in a newline, the trailing
+// comma state of other
+func withCommaOf(line string, other string) string {
+	newline := ""
+	if strings.HasSuffix(line, "\n") {
+		line, newline = line[:len(line)-1], "\n"
+	}
+	line, _ = splitTrailingComma(line)
+	if _, comma := splitTrailingComma(strings.TrimSuffix(other, "\n")); comma {
+		line += ","
+	}
+	return line + newline
+}
+
+func hasMatchers(s string) bool {
+	return matcherStartRegexp.MatchString(s)
+}
+
+// resolveMatchers pairs expected lines containing matchers with the
+// actual lines they match. It returns the expected baseline with
+// matched lines replaced by their actual values, for comparing, and
+// the actual baseline with matched lines replaced by their matchers,
+// for rebaselining without losing hand written matchers. Each line
+// keeps its own trailing comma, so a comma added or removed after a
+// matcher still shows up as a difference and is still rebaselined.
+func resolveMatchers(expected string, actual string) (string, string) {
+	if !hasMatchers(expected) {
+		return expected, actual
+	}
+	expLines := strings.SplitAfter(expected, "\n")
+	actLines := strings.SplitAfter(actual, "\n")
+	resolved := append([]string{}, expLines...)
+	preserved := append([]string{}, actLines...)
+	matcher := difflib.NewMatcher(expLines, actLines)
+	for _, op := range matcher.GetOpCodes() {
+		if op.Tag != 'r' {
+			continue
+		}
+		j := op.J1
+		for i := op.I1; i < op.I2 && j < op.J2; i++ {
+			re := compileMatcherLine(strings.TrimSuffix(expLines[i], "\n"))
+			if re == nil {
+				continue
+			}
+			for k := j; k < op.J2; k++ {
+				if re.MatchString(strings.TrimSuffix(actLines[k], "\n")) {
+					resolved[i] = withCommaOf(actLines[k], expLines[i])
+					preserved[k] = withCommaOf(expLines[i], actLines[k])
+					j = k + 1
+					break
+				}
+			}
+		}
+	}
+	return strings.Join(resolved, ""), strings.Join(preserved, "")
+}
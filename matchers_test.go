@@ -0,0 +1,102 @@
+package httpbaselinetest
+
+import (
+	"testing"
+)
+
+func TestCompileMatcherLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		input   string
+		matches bool
+	}{
+		{`  "id": "{{uuid}}",`, `  "id": "8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4",`, true},
+		{`  "id": "{{uuid}}",`, `  "id": "8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4"`, true},
+		{`  "id": "{{uuid}}"`, `  "id": "8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4",`, true},
+		{`  "id": "{{uuid}}"`, `  "id": "not-a-uuid"`, false},
+		{`  "at": "{{rfc3339}}"`, `  "at": "2020-01-01T00:00:00Z"`, true},
+		{`  "at": "{{rfc3339}}"`, `  "at": "2020-01-01"`, false},
+		{`  "n": {{regex:[0-9]+}}`, `  "n": 42`, true},
+		{`  "n": {{regex:[0-9]+}}`, `  "n": "42"`, false},
+		{`  "x": {{any}}`, `  "x": [1, 2]`, true},
+		{`Date: {{any}}`, `Date: Mon, 01 Jan 2020 00:00:00 GMT`, true},
+		{`  "n": "{{regex:a{2}}}"`, `  "n": "aa"`, true},
+		{`  "n": "{{regex:a{2}}}"`, `  "n": "a{2}"`, false},
+		{`{{regex:[0-9]{2,3}}}-{{uuid}}`, `123-8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4`, true},
+		{`{{regex:[0-9]{2,3}}}-{{uuid}}`, `1-8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4`, false},
+		{`{{ regex:[}]+ }} {{ any }}`, `}} x`, true},
+		{`{{regex:\{}}`, `{`, true},
+	}
+	for _, tt := range tests {
+		re := compileMatcherLine(tt.line)
+		if re == nil {
+			t.Fatalf("%q did not compile", tt.line)
+		}
+		if got := re.MatchString(tt.input); got != tt.matches {
+			t.Errorf("%q matching %q = %v, want %v", tt.line, tt.input, got, tt.matches)
+		}
+	}
+	for _, line := range []string{`  "id": "abc"`, `{{regex:[}}`} {
+		if re := compileMatcherLine(line); re != nil {
+			t.Errorf("%q compiled to %s, want nil", line, re)
+		}
+	}
+}
+
+func TestResolveMatchers(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  string
+		actual    string
+		resolved  string
+		preserved string
+	}{
+		{
+			name:      "no matchers",
+			expected:  "{\n  \"a\": 1\n}\n",
+			actual:    "{\n  \"a\": 2\n}\n",
+			resolved:  "{\n  \"a\": 1\n}\n",
+			preserved: "{\n  \"a\": 2\n}\n",
+		},
+		{
+			name:      "match",
+			expected:  "{\n  \"id\": \"{{uuid}}\",\n  \"n\": 1\n}\n",
+			actual:    "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\",\n  \"n\": 1\n}\n",
+			resolved:  "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\",\n  \"n\": 1\n}\n",
+			preserved: "{\n  \"id\": \"{{uuid}}\",\n  \"n\": 1\n}\n",
+		},
+		{
+			name:      "no match",
+			expected:  "{\n  \"id\": \"{{uuid}}\"\n}\n",
+			actual:    "{\n  \"id\": \"nope\"\n}\n",
+			resolved:  "{\n  \"id\": \"{{uuid}}\"\n}\n",
+			preserved: "{\n  \"id\": \"nope\"\n}\n",
+		},
+		{
+			name:     "key added after matcher",
+			expected: "{\n  \"id\": \"{{uuid}}\"\n}\n",
+			actual:   "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\",\n  \"new\": true\n}\n",
+			// the comma is still a difference when comparing
+			resolved:  "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\"\n}\n",
+			preserved: "{\n  \"id\": \"{{uuid}}\",\n  \"new\": true\n}\n",
+		},
+		{
+			name:      "key removed after matcher",
+			expected:  "{\n  \"id\": \"{{uuid}}\",\n  \"old\": true\n}\n",
+			actual:    "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\"\n}\n",
+			resolved:  "{\n  \"id\": \"8fd7f84c-ce1c-463c-ba3f-ea81725f1eb4\",\n  \"old\": true\n}\n",
+			preserved: "{\n  \"id\": \"{{uuid}}\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, preserved := resolveMatchers(tt.expected, tt.actual)
+			if resolved != tt.resolved {
+				t.Errorf("resolved = %q, want %q", resolved, tt.resolved)
+			}
+			if preserved != tt.preserved {
+				t.Errorf("preserved = %q, want %q", preserved, tt.preserved)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package httpbaselinetest
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// RebaselineMode controls when a Suite rewrites baseline files
+// instead of comparing against them.
+type RebaselineMode int
+
+const (
+	// RebaselineFromEnv picks the mode from the REBASELINE
+	// environment variable: unset is RebaselineNever, "missing"
+	// is RebaselineMissing, "failed" is RebaselineFailed and "1"
+	// or "true" is RebaselineAlways. It may also list the
+	// artifacts to rebaseline, e.g. "resp,db" or "failed,resp",
+	// which alone means RebaselineAlways for those artifacts. Any
+	// other value fails the suite. This is the default.
+	RebaselineFromEnv RebaselineMode = iota
+	// RebaselineNever always compares against existing baselines.
+	RebaselineNever
+	// RebaselineAlways always rewrites baselines.
+	RebaselineAlways
+	// RebaselineMissing writes baselines that do not exist yet
+	// and compares the rest.
+	RebaselineMissing
+	// RebaselineFailed rewrites baselines that are missing or do
+	// not match, leaving matching baselines untouched.
+	RebaselineFailed
+)
+
+// Artifact is a kind of baseline recorded for each test.
+type Artifact string
+
+const (
+	ArtifactRequest  Artifact = "req"
+	ArtifactResponse Artifact = "resp"
+	ArtifactDb       Artifact = "db"
+)
+
+func parseArtifact(s string) (Artifact, bool) {
+	switch s {
+	case "req", "request":
+		return ArtifactRequest, true
+	case "resp", "response":
+		return ArtifactResponse, true
+	case "db":
+		return ArtifactDb, true
+	}
+	return "", false
+}
+
+// BaselineFormat selects how the baselines of a test are laid out
+// on disk.
+type BaselineFormat int
+
+const (
+	// BaselineSplit writes a .req.txt, .resp.txt and .db.json
+	// file for each test. This is the default.
+	BaselineSplit BaselineFormat = iota
+	// BaselineCombined writes a single txtar style .txtar file
+	// for each test with request, response and db sections.
+	BaselineCombined
+)
+
+// artifactSelected reports whether artifact is in artifacts. An
+// empty list selects every artifact.
+func artifactSelected(artifacts []Artifact, artifact Artifact) bool {
+	if len(artifacts) == 0 {
+		return true
+	}
+	for _, a := range artifacts {
+		if a == artifact {
+			return true
+		}
+	}
+	return false
+}
+
+type suiteOptions struct {
+	baselineDir    string
+	seedDir        string
+	rebaselineMode RebaselineMode
+	// nil means all artifacts
+	rebaselineArtifacts map[Artifact]bool
+	headers             map[string]string
+	setup               SetupFunc
+	teardown            TeardownFunc
+	db                  *sqlx.DB
+	baselineFormat      BaselineFormat
+	baselinePathFunc    BaselinePathFunc
+	parallel            bool
+	checkOrphans        bool
+	scrubbers           []Scrubber
+	uuidAliasing        UUIDAliasing
+	headerRules         []HeaderRule
+	arraySorts          []ArraySort
+	formatters          *FormatterRegistry
+	diffFormat          DiffFormat
+	jsonReportPath      string
+	junitReportPath     string
+}
+
+// SuiteOption configures a Suite created with NewSuite.
+type SuiteOption func(*suiteOptions)
+
+func defaultSuiteOptions() suiteOptions {
+	return suiteOptions{
+		baselineDir:      "testdata",
+		rebaselineMode:   RebaselineFromEnv,
+		baselinePathFunc: FlatBaselinePath,
+		formatters:       defaultFormatters.clone(),
+	}
+}
+
+// WithBaselineDir sets the directory baselines are read from and
+// written to. The default is "testdata".
+func WithBaselineDir(dir string) SuiteOption {
+	return func(o *suiteOptions) {
+		o.baselineDir = dir
+	}
+}
+
+// WithSeedDir sets the directory HTTPBaselineTest.Seed files are
+// resolved against. The default is the baseline directory.
+func WithSeedDir(dir string) SuiteOption {
+	return func(o *suiteOptions) {
+		o.seedDir = dir
+	}
+}
+
+// WithRebaseline sets when baselines are rewritten.
+func WithRebaseline(mode RebaselineMode) SuiteOption {
+	return func(o *suiteOptions) {
+		o.rebaselineMode = mode
+	}
+}
+
+// WithRebaselineArtifacts limits rebaselining to the given kinds of
+// artifacts. The others are still compared. Artifacts listed in the
+// REBASELINE environment variable, e.g. REBASELINE=resp,db, take
+// precedence when using RebaselineFromEnv.
+func WithRebaselineArtifacts(artifacts ...Artifact) SuiteOption {
+	return func(o *suiteOptions) {
+		o.rebaselineArtifacts = make(map[Artifact]bool)
+		for _, artifact := range artifacts {
+			o.rebaselineArtifacts[artifact] = true
+		}
+	}
+}
+
+// WithDefaultHeaders sets headers added to every request. Headers
+// set on an HTTPBaselineTest take precedence.
+func WithDefaultHeaders(headers map[string]string) SuiteOption {
+	return func(o *suiteOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		for k, v := range headers {
+			o.headers[k] = v
+		}
+	}
+}
+
+// WithDefaultSetup sets the SetupFunc used by tests that do not
+// provide their own.
+func WithDefaultSetup(setup SetupFunc) SuiteOption {
+	return func(o *suiteOptions) {
+		o.setup = setup
+	}
+}
+
+// WithDefaultTeardown sets the TeardownFunc used by tests that do
+// not provide their own.
+func WithDefaultTeardown(teardown TeardownFunc) SuiteOption {
+	return func(o *suiteOptions) {
+		o.teardown = teardown
+	}
+}
+
+// WithDefaultDb sets the database used by tests that do not provide
+// their own. The suite does not close the default database.
+func WithDefaultDb(db *sqlx.DB) SuiteOption {
+	return func(o *suiteOptions) {
+		o.db = db
+	}
+}
+
+// WithParallel runs each test in the suite as a parallel subtest.
+// Tests that share a database are still run one at a time so their
+// seeds and table statistics do not interfere.
+func WithParallel() SuiteOption {
+	return func(o *suiteOptions) {
+		o.parallel = true
+	}
+}
+
+// WithOrphanedBaselineCheck fails the tests if the baseline
+// directory contains baselines no suite used, e.g. after a test was
+// renamed, and deletes them when rebaselining. It requires calling
+// RunWithOrphanedBaselineCheck from TestMain, and fails the suite
+// otherwise. The check can't run in the suite's t.Cleanup: every
+// TestXxx function usually has its own suite writing to the same
+// testdata directory, and when one suite finishes the suites that run
+// after it have not used their baselines yet, so they would be
+// reported, or deleted when rebaselining. Orphans are printed and make
+// the test binary exit with a non-zero code, which fails go test.
+// The check is skipped when tests are filtered with -run or -skip.
+func WithOrphanedBaselineCheck() SuiteOption {
+	return func(o *suiteOptions) {
+		o.checkOrphans = true
+	}
+}
+
+// WithBaselineFormat sets the layout new baselines are written in.
+// Baselines in either layout can be read, so existing baselines keep
+// working until they are rewritten.
+func WithBaselineFormat(format BaselineFormat) SuiteOption {
+	return func(o *suiteOptions) {
+		o.baselineFormat = format
+	}
+}
+
+// WithBaselinePathFunc sets how a test's name is mapped to the path
+// of its baselines, e.g. NestedBaselinePath or RouteBaselinePath.
+func WithBaselinePathFunc(f BaselinePathFunc) SuiteOption {
+	return func(o *suiteOptions) {
+		o.baselinePathFunc = f
+	}
+}
+
+// WithScrubbers sets scrubbers applied to every test, before the
+// test's own HTTPBaselineTest.Scrubbers.
+func WithScrubbers(scrubbers ...Scrubber) SuiteOption {
+	return func(o *suiteOptions) {
+		o.scrubbers = append(o.scrubbers, scrubbers...)
+	}
+}
+
+// WithUUIDAliasing replaces UUIDs in baselines with stable aliases
+// so generated ids do not change the baselines on every run.
+func WithUUIDAliasing(mode UUIDAliasing) SuiteOption {
+	return func(o *suiteOptions) {
+		o.uuidAliasing = mode
+	}
+}
+
+// WithHeaderRules sets header rules applied to every test, before
+// the test's own HTTPBaselineTest.HeaderRules.
+func WithHeaderRules(rules ...HeaderRule) SuiteOption {
+	return func(o *suiteOptions) {
+		o.headerRules = append(o.headerRules, rules...)
+	}
+}
+
+// WithArraySorts sets array sorts applied to every test, before the
+// test's own HTTPBaselineTest.ArraySorts.
+func WithArraySorts(arraySorts ...ArraySort) SuiteOption {
+	return func(o *suiteOptions) {
+		o.arraySorts = append(o.arraySorts, arraySorts...)
+	}
+}
+
+// WithBodyFormatter registers a formatter for a media type, a
+// structured syntax suffix like "+json" or a wildcard like "text/*".
+func WithBodyFormatter(mediaType string, formatter BodyFormatter) SuiteOption {
+	return func(o *suiteOptions) {
+		o.formatters.Register(mediaType, formatter)
+	}
+}
+
+// WithXMLAttributeSorting writes the attributes of XML elements in
+// name order
+func WithXMLAttributeSorting() SuiteOption {
+	return func(o *suiteOptions) {
+		o.formatters.registerXML(XMLFormatter{SortAttributes: true})
+	}
+}
+
+// WithDiffFormat sets how baseline mismatches are reported
+func WithDiffFormat(format DiffFormat) SuiteOption {
+	return func(o *suiteOptions) {
+		o.diffFormat = format
+	}
+}
+
+// WithJSONReport writes a JSON report of every test, with the status
+// and diff of each of its baselines, to path after the suite's tests
+// have run. Suites using the same path share one report.
+func WithJSONReport(path string) SuiteOption {
+	return func(o *suiteOptions) {
+		o.jsonReportPath = path
+	}
+}
+
+// WithJUnitReport writes a JUnit XML report of the suite's tests to
+// path after they have run. Suites using the same path share one
+// report, with a testsuite for each.
+func WithJUnitReport(path string) SuiteOption {
+	return func(o *suiteOptions) {
+		o.junitReportPath = path
+	}
+}
@@ -0,0 +1,202 @@
+package httpbaselinetest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var baselineFileSuffixes = []string{".req.txt", ".resp.txt", ".db.json", ".txtar"}
+
+// sidecarFileInfixes mark the files holding binary bodies, e.g.
+// get_v1_logo.resp.body.png
+var sidecarFileInfixes = []string{".req.body.", ".resp.body."}
+
+func isBaselineFile(name string) bool {
+	for _, suffix := range baselineFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	for _, infix := range sidecarFileInfixes {
+		if strings.Contains(name, infix) {
+			return true
+		}
+	}
+	return false
+}
+
+// touchPath records that a baseline or seed file is used by a test
+func (suite *Suite) touchPath(path string) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.touchedPaths[filepath.Clean(path)] = true
+}
+
+// testFilterFlag returns the first of -run or -skip that is set, as
+// orphans cannot be detected when only some tests are run
+func testFilterFlag() string {
+	for _, name := range []string{"test.run", "test.skip"} {
+		f := flag.Lookup(name)
+		if f != nil && f.Value.String() != "" {
+			return strings.TrimPrefix(name, "test.")
+		}
+	}
+	return ""
+}
+
+// orphanRegistry collects the baselines used by every suite in the
+// test binary, so that a baseline directory is checked against all
+// of the suites writing to it, not just the ones that check it
+type orphanRegistry struct {
+	sync.Mutex
+	touched map[string]bool
+	// the baseline directories of suites created with
+	// WithOrphanedBaselineCheck
+	checkedDirs map[string]bool
+	// whether every suite is rebaselining every artifact
+	prune bool
+	// whether a suite failed or was skipped
+	incomplete bool
+	// set by RunWithOrphanedBaselineCheck
+	deferred bool
+}
+
+func newOrphanRegistry() *orphanRegistry {
+	return &orphanRegistry{
+		touched:     make(map[string]bool),
+		checkedDirs: make(map[string]bool),
+		prune:       true,
+	}
+}
+
+var orphans = newOrphanRegistry()
+
+// registerOrphanCheck adds the suite to the orphan check. Every
+// suite is registered, as a suite that does not check for orphans
+// may still use baselines in a directory that is checked.
+func (suite *Suite) registerOrphanCheck() {
+	orphans.Lock()
+	defer orphans.Unlock()
+	if suite.opts.checkOrphans {
+		orphans.checkedDirs[filepath.Clean(suite.opts.baselineDir)] = true
+	}
+	mode, artifacts := suite.rebaselineMode()
+	orphans.prune = orphans.prune && mode == RebaselineAlways && artifacts == nil
+}
+
+// finishOrphanCheck records the paths the suite used once all of its
+// tests have run. A suite checking for orphans fails unless
+// RunWithOrphanedBaselineCheck runs the check after all suites.
+func (suite *Suite) finishOrphanCheck() {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	orphans.Lock()
+	defer orphans.Unlock()
+	for path := range suite.touchedPaths {
+		orphans.touched[path] = true
+	}
+	if suite.t.Failed() || suite.t.Skipped() {
+		orphans.incomplete = true
+	}
+	if suite.opts.checkOrphans && !orphans.deferred {
+		suite.t.Errorf("WithOrphanedBaselineCheck requires calling " +
+			"RunWithOrphanedBaselineCheck from TestMain, as suites that have not " +
+			"run yet may use baselines in the same directory")
+	}
+}
+
+func findOrphanedBaselines(dir string, touched map[string]bool) ([]string, error) {
+	orphans := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isBaselineFile(info.Name()) {
+			return nil
+		}
+		if !touched[filepath.Clean(path)] {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return orphans, nil
+	}
+	sort.Strings(orphans)
+	return orphans, err
+}
+
+// RunWithOrphanedBaselineCheck runs the tests, then checks each
+// baseline directory used by a suite created with
+// WithOrphanedBaselineCheck against the baselines used by every
+// suite in the package. When every suite was rebaselining, orphaned
+// baselines are deleted; otherwise they are reported and the exit
+// code is non-zero. It must be called from TestMain when using
+// WithOrphanedBaselineCheck:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(httpbaselinetest.RunWithOrphanedBaselineCheck(m))
+//	}
+func RunWithOrphanedBaselineCheck(m *testing.M) int {
+	orphans.Lock()
+	orphans.deferred = true
+	orphans.Unlock()
+	code := m.Run()
+	if code != 0 {
+		return code
+	}
+	if flagName := testFilterFlag(); flagName != "" {
+		fmt.Printf("Skipping orphaned baseline check because -%s is set\n", flagName)
+		return code
+	}
+	if !orphans.check(os.Stdout) {
+		code = 1
+	}
+	return code
+}
+
+// check reports or removes the orphaned baselines in the checked
+// directories, and returns false if any were reported
+func (reg *orphanRegistry) check(w io.Writer) bool {
+	reg.Lock()
+	defer reg.Unlock()
+	if reg.incomplete {
+		return true
+	}
+	dirs := make([]string, 0, len(reg.checkedDirs))
+	for dir := range reg.checkedDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	ok := true
+	for _, dir := range dirs {
+		orphaned, err := findOrphanedBaselines(dir, reg.touched)
+		if err != nil {
+			fmt.Fprintf(w, "Error finding orphaned baselines: %s\n", err)
+			ok = false
+			continue
+		}
+		for _, orphan := range orphaned {
+			if !reg.prune {
+				fmt.Fprintf(w, "Orphaned baseline %s is not used by any test\n", orphan)
+				ok = false
+				continue
+			}
+			err := os.Remove(orphan)
+			if err != nil {
+				fmt.Fprintf(w, "Error removing orphaned baseline %s: %s\n", orphan, err)
+				ok = false
+				continue
+			}
+			fmt.Fprintf(w, "Removed orphaned baseline %s\n", orphan)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,71 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrphanedBaselinesSharedDir(t *testing.T) {
+	saved := orphans
+	defer func() { orphans = saved }()
+	dir := t.TempDir()
+	orphan := filepath.Join(dir, "renamed.req.txt")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	run := func(mode RebaselineMode) {
+		orphans = newOrphanRegistry()
+		orphans.deferred = true
+		err := ioutil.WriteFile(orphan, []byte("GET /renamed\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only one of the suites sharing the directory checks it
+		t.Run("TestA", func(t *testing.T) {
+			bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(mode),
+				WithOrphanedBaselineCheck())
+			bts.Run("a", HTTPBaselineTest{Handler: handler, Method: "GET", Path: "/a"})
+		})
+		t.Run("TestB", func(t *testing.T) {
+			bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(mode))
+			bts.Run("b", HTTPBaselineTest{Handler: handler, Method: "GET", Path: "/b"})
+		})
+	}
+	assertExists := func(names ...string) {
+		t.Helper()
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("%s was removed: %s", name, err)
+			}
+		}
+	}
+
+	run(RebaselineAlways)
+	var out bytes.Buffer
+	if !orphans.check(&out) {
+		t.Fatalf("check failed when rebaselining:\n%s", out.String())
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("orphaned baseline was not removed:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "b.re") {
+		t.Errorf("removed a baseline used by the other suite:\n%s", out.String())
+	}
+	assertExists("a.req.txt", "a.resp.txt", "b.req.txt", "b.resp.txt")
+
+	run(RebaselineNever)
+	out.Reset()
+	if orphans.check(&out) {
+		t.Fatal("check passed with an orphaned baseline")
+	}
+	expected := "Orphaned baseline " + orphan + " is not used by any test\n"
+	if out.String() != expected {
+		t.Errorf("check output = %q, want %q", out.String(), expected)
+	}
+	assertExists("renamed.req.txt", "a.req.txt", "a.resp.txt", "b.req.txt", "b.resp.txt")
+}
@@ -0,0 +1,58 @@
+package httpbaselinetest
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// BaselinePathInfo describes the test a baseline path is generated
+// for.
+type BaselinePathInfo struct {
+	// TestName is the name passed to Suite.Run
+	TestName string
+	// FullName is the name of the subtest including its parents,
+	// as returned by t.Name()
+	FullName string
+	Method   string
+	Path     string
+}
+
+// BaselinePathFunc returns the path of a test's baselines, relative
+// to the baseline directory and without an extension.
+type BaselinePathFunc func(info BaselinePathInfo) string
+
+// FlatBaselinePath puts every baseline directly in the baseline
+// directory, named after the normalized test name. This is the
+// default.
+func FlatBaselinePath(info BaselinePathInfo) string {
+	return NormalizeTestName(info.TestName)
+}
+
+// NestedBaselinePath puts baselines in a directory for each parent
+// test, e.g. TestCars/POST_car is stored as testcars/post_car.
+func NestedBaselinePath(info BaselinePathInfo) string {
+	parts := strings.Split(info.FullName, "/")
+	for i := range parts {
+		parts[i] = NormalizeTestName(parts[i])
+	}
+	return path.Join(parts...)
+}
+
+// RouteBaselinePath groups baselines by HTTP method and request
+// path, e.g. a POST to /api/v1/car is stored as
+// post/api/v1/car/<test name>.
+func RouteBaselinePath(info BaselinePathInfo) string {
+	parts := []string{NormalizeTestName(info.Method)}
+	routePath := info.Path
+	if u, err := url.Parse(info.Path); err == nil {
+		routePath = u.Path
+	}
+	for _, segment := range strings.Split(routePath, "/") {
+		if segment != "" {
+			parts = append(parts, NormalizeTestName(segment))
+		}
+	}
+	parts = append(parts, NormalizeTestName(info.TestName))
+	return path.Join(parts...)
+}
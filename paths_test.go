@@ -0,0 +1,45 @@
+package httpbaselinetest
+
+import "testing"
+
+func TestBaselinePathFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        BaselinePathFunc
+		info     BaselinePathInfo
+		expected string
+	}{
+		{"flat", FlatBaselinePath,
+			BaselinePathInfo{TestName: "POST v1/car", FullName: "TestCars/POST_v1/car"},
+			"post_v1_car"},
+		{"nested", NestedBaselinePath,
+			BaselinePathInfo{TestName: "POST car", FullName: "TestCars/POST_car"},
+			"testcars/post_car"},
+		{"nested subtests", NestedBaselinePath,
+			BaselinePathInfo{TestName: "get", FullName: "TestCars/v1/get"},
+			"testcars/v1/get"},
+		{"nested repeated name", NestedBaselinePath,
+			BaselinePathInfo{TestName: "get", FullName: "TestCars/get#01"},
+			"testcars/get_01"},
+		{"route", RouteBaselinePath,
+			BaselinePathInfo{TestName: "with auth", Method: "POST", Path: "/api/v1/car"},
+			"post/api/v1/car/with_auth"},
+		{"route with query", RouteBaselinePath,
+			BaselinePathInfo{TestName: "page 2", Method: "GET", Path: "/cars?page=2&sort=name"},
+			"get/cars/page_2"},
+		{"route with id", RouteBaselinePath,
+			BaselinePathInfo{TestName: "get", Method: "GET", Path: "/cars/42//owners/"},
+			"get/cars/42/owners/get"},
+		{"root route", RouteBaselinePath,
+			BaselinePathInfo{TestName: "index", Method: "GET", Path: "/"},
+			"get/index"},
+		{"empty route", RouteBaselinePath,
+			BaselinePathInfo{TestName: "index", Method: "GET", Path: ""},
+			"get/index"},
+	}
+	for _, tt := range tests {
+		if got := tt.f(tt.info); got != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.expected)
+		}
+	}
+}
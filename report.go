@@ -0,0 +1,264 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Artifact statuses in reports
+const (
+	artifactPassed      = "pass"
+	artifactFailed      = "fail"
+	artifactRebaselined = "rebaselined"
+	artifactCreated     = "created"
+)
+
+type artifactResult struct {
+	Artifact Artifact `json:"artifact"`
+	Path     string   `json:"path"`
+	Status   string   `json:"status"`
+	Summary  string   `json:"summary,omitempty"`
+	Diff     string   `json:"diff,omitempty"`
+}
+
+type testResult struct {
+	Name      string           `json:"name"`
+	Status    string           `json:"status"`
+	Duration  float64          `json:"durationSeconds"`
+	Artifacts []artifactResult `json:"artifacts"`
+}
+
+type testReport struct {
+	Suite    string        `json:"suite"`
+	Tests    int           `json:"tests"`
+	Failures int           `json:"failures"`
+	Results  []*testResult `json:"results"`
+}
+
+// jsonReport is the JSON report of every suite writing to a path
+type jsonReport struct {
+	Tests    int          `json:"tests"`
+	Failures int          `json:"failures"`
+	Suites   []testReport `json:"suites"`
+}
+
+// startTestResult records a test for the suite's reports. Its status
+// and duration are filled in when the test finishes.
+func (suite *Suite) startTestResult(t *testing.T) *testResult {
+	if suite.opts.jsonReportPath == "" && suite.opts.junitReportPath == "" {
+		return nil
+	}
+	result := &testResult{
+		Name:      t.Name(),
+		Artifacts: []artifactResult{},
+	}
+	start := time.Now()
+	t.Cleanup(func() {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		result.Duration = time.Since(start).Seconds()
+		switch {
+		case t.Skipped():
+			result.Status = "skip"
+		case t.Failed():
+			result.Status = "fail"
+		default:
+			result.Status = "pass"
+		}
+	})
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.results = append(suite.results, result)
+	return result
+}
+
+func (r *httpBaselineTestRunner) recordArtifact(artifact Artifact, path string, status string,
+	summary string, diff string) {
+	if r.result == nil {
+		return
+	}
+	r.suite.mu.Lock()
+	defer r.suite.mu.Unlock()
+	r.result.Artifacts = append(r.result.Artifacts, artifactResult{
+		Artifact: artifact,
+		Path:     path,
+		Status:   status,
+		Summary:  summary,
+		Diff:     diff,
+	})
+}
+
+func (suite *Suite) buildReport() testReport {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	report := testReport{
+		Suite:   suite.t.Name(),
+		Results: append([]*testResult{}, suite.results...),
+	}
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].Name < report.Results[j].Name
+	})
+	report.Tests = len(report.Results)
+	for _, result := range report.Results {
+		if result.Status == "fail" {
+			report.Failures++
+		}
+	}
+	return report
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func (report *testReport) junit() junitTestSuite {
+	suite := junitTestSuite{
+		Name:     report.Suite,
+		Tests:    report.Tests,
+		Failures: report.Failures,
+	}
+	total := 0.0
+	for _, result := range report.Results {
+		total += result.Duration
+		tc := junitTestCase{
+			Name:      strings.TrimPrefix(result.Name, report.Suite+"/"),
+			ClassName: report.Suite,
+			Time:      fmt.Sprintf("%.3f", result.Duration),
+		}
+		switch result.Status {
+		case "skip":
+			suite.Skipped++
+			tc.Skipped = &struct{}{}
+		case "fail":
+			summaries := []string{}
+			details := []string{}
+			for _, a := range result.Artifacts {
+				if a.Status == artifactFailed {
+					summaries = append(summaries, artifactSection(a.Artifact)+": "+a.Summary)
+					details = append(details, a.Path+"\n"+a.Diff)
+				}
+			}
+			tc.Failure = &junitFailure{Message: "test failed"}
+			if len(summaries) > 0 {
+				tc.Failure.Message = strings.Join(summaries, "; ")
+				tc.Failure.Text = strings.Join(details, "\n")
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total)
+	return suite
+}
+
+func encodeJSONReport(reports []testReport) ([]byte, error) {
+	jr := jsonReport{Suites: reports}
+	for _, report := range reports {
+		jr.Tests += report.Tests
+		jr.Failures += report.Failures
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	// keep diffs readable
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(jr)
+	return buf.Bytes(), err
+}
+
+func encodeJUnitReport(reports []testReport) ([]byte, error) {
+	suites := junitTestSuites{}
+	for i := range reports {
+		suites.Suites = append(suites.Suites, reports[i].junit())
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// reportRegistry collects the reports of every suite in the test
+// binary by path, as there is usually a suite for each TestXxx
+// function and they would otherwise overwrite each other's reports
+type reportRegistry struct {
+	sync.Mutex
+	reports map[string][]testReport
+}
+
+var reportFiles = &reportRegistry{reports: make(map[string][]testReport)}
+
+// write adds a suite's report to the reports written to path, and
+// rewrites the file with all of them
+func (reg *reportRegistry) write(path string, report testReport,
+	encode func([]testReport) ([]byte, error)) error {
+	reg.Lock()
+	defer reg.Unlock()
+	key := filepath.Clean(path)
+	reg.reports[key] = append(reg.reports[key], report)
+	data, err := encode(reg.reports[key])
+	if err != nil {
+		return err
+	}
+	return writeReportFile(path, data)
+}
+
+func writeReportFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	// #nosec G306 -- reports are read by CI
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// writeReports adds the suite's tests to the JSON and JUnit XML
+// reports after they have run. Suites sharing a path are written to
+// the same report.
+func (suite *Suite) writeReports() {
+	report := suite.buildReport()
+	if suite.opts.jsonReportPath != "" {
+		err := reportFiles.write(suite.opts.jsonReportPath, report, encodeJSONReport)
+		if err != nil {
+			suite.t.Errorf("Error writing JSON report %s: %s", suite.opts.jsonReportPath, err)
+		}
+	}
+	if suite.opts.junitReportPath != "" {
+		err := reportFiles.write(suite.opts.junitReportPath, report, encodeJUnitReport)
+		if err != nil {
+			suite.t.Errorf("Error writing JUnit report %s: %s", suite.opts.junitReportPath, err)
+		}
+	}
+}
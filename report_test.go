@@ -0,0 +1,61 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestReportsSharedPath(t *testing.T) {
+	saved := reportFiles
+	defer func() { reportFiles = saved }()
+	reportFiles = &reportRegistry{reports: make(map[string][]testReport)}
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "reports", "baselines.json")
+	junitPath := filepath.Join(dir, "reports", "baselines.xml")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	for _, name := range []string{"TestA", "TestB"} {
+		t.Run(name, func(t *testing.T) {
+			bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(RebaselineAlways),
+				WithJSONReport(jsonPath), WithJUnitReport(junitPath))
+			bts.Run("get", HTTPBaselineTest{Handler: handler, Method: "GET", Path: "/"})
+		})
+	}
+
+	data, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jr jsonReport
+	err = json.Unmarshal(data, &jr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jr.Tests != 2 || len(jr.Suites) != 2 {
+		t.Fatalf("JSON report has %d tests in %d suites, want 2 in 2:\n%s",
+			jr.Tests, len(jr.Suites), data)
+	}
+	for i, name := range []string{"TestReportsSharedPath/TestA", "TestReportsSharedPath/TestB"} {
+		if jr.Suites[i].Suite != name {
+			t.Errorf("JSON report suite %d = %s, want %s", i, jr.Suites[i].Suite, name)
+		}
+	}
+
+	data, err = ioutil.ReadFile(junitPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	err = xml.Unmarshal(data, &suites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 2 {
+		t.Errorf("JUnit report has %d suites, want 2:\n%s", len(suites.Suites), data)
+	}
+}
@@ -8,18 +8,22 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"sort"
 	"strings"
 )
 
-func formatRequest(r *http.Request) (string, []byte, error) {
+func formatRequest(r *http.Request, fo *formatOptions) (string, []byte, error) {
 	// Create return string
 	var request []string
 	// Add the request string
-	url := fmt.Sprintf("%v %v %v", r.Method, r.URL, r.Proto)
-	request = append(request, url)
+	requestLine := fmt.Sprintf("%v %v %v", r.Method, r.URL, r.Proto)
+	request = append(request, requestLine)
+	request = append(request, formatQuery(r.URL.RawQuery)...)
 	// Add the host
-	request = append(request, fmt.Sprintf("Host: %v", r.Host))
+	for _, host := range fo.headerValues(ArtifactRequest, "Host", []string{r.Host}) {
+		request = append(request, fmt.Sprintf("Host: %v", host))
+	}
 
 	if len(r.TransferEncoding) > 0 {
 		request = append(request, fmt.Sprintf("Transfer-Encoding: %s", strings.Join(r.TransferEncoding, ",")))
@@ -37,7 +41,11 @@ func formatRequest(r *http.Request) (string, []byte, error) {
 
 	// Loop through headers
 	for _, k := range keys {
-		for _, h := range r.Header.Values(k) {
+		values := r.Header.Values(k)
+		if k == "Content-Type" {
+			values = normalizeContentTypes(values)
+		}
+		for _, h := range fo.headerValues(ArtifactRequest, k, values) {
 			request = append(request, fmt.Sprintf("%v: %v", k, h))
 		}
 	}
@@ -57,17 +65,44 @@ func formatRequest(r *http.Request) (string, []byte, error) {
 		ctype = r.Header.Get("Content-type")
 	}
 	if len(body) > 0 {
-		formattedBody, err := formatBody(ctype, body)
+		formattedBody, decoded, err := fo.formatEncodedBody(ArtifactRequest, ctype, r.Header, body)
 		if err != nil {
 			return "", nil, err
 		}
 		request = append(request, formattedBody)
+		body = decoded
 	}
 
 	// Return the request as a string
 	return strings.Join(request, "\n"), body, nil
 }
 
+// formatQuery returns the decoded query parameters, indented under
+// the request line, in the order they were sent
+func formatQuery(rawQuery string) []string {
+	lines := []string{}
+	if rawQuery == "" {
+		return lines
+	}
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			key, value = param[:i], param[i+1:]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		lines = append(lines, fmt.Sprintf("    %s: %s", formFieldText(key), formFieldText(value)))
+	}
+	return lines
+}
+
 func (r *httpBaselineTestRunner) buildRequest() *http.Request {
 
 	var bodyReader io.Reader
@@ -85,11 +120,34 @@ func (r *httpBaselineTestRunner) buildRequest() *http.Request {
 		}
 		bodyReader = bytes.NewReader(data)
 	}
-	req := httptest.NewRequest(r.btest.Method, r.btest.Path, bodyReader)
+	target := r.btest.Path
+	if len(r.btest.Query) > 0 {
+		u, err := url.Parse(target)
+		if err != nil {
+			r.t.Fatalf("Error parsing path %s: %s", target, err)
+		}
+		query := u.Query()
+		for key, values := range r.btest.Query {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		// Encode sorts by key
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+	req := httptest.NewRequest(r.btest.Method, target, bodyReader)
 	if r.btest.Host != "" {
 		req.Host = r.btest.Host
 	}
+	headers := make(map[string]string)
+	for key, val := range r.suite.opts.headers {
+		headers[http.CanonicalHeaderKey(key)] = val
+	}
 	for key, val := range r.btest.Headers {
+		headers[http.CanonicalHeaderKey(key)] = val
+	}
+	for key, val := range headers {
 		req.Header.Add(key, val)
 	}
 	for i := range r.btest.Cookies {
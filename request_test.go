@@ -0,0 +1,79 @@
+package httpbaselinetest
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFormatQuery(t *testing.T) {
+	tests := []struct {
+		rawQuery string
+		expected []string
+	}{
+		{"", []string{}},
+		{"b=2&a=1&b=1", []string{"    b: 2", "    a: 1", "    b: 1"}},
+		{"flag&empty=", []string{"    flag: ", "    empty: "}},
+		{"q=a%26b%2Bc+d", []string{"    q: a&b+c d"}},
+		{"note=line1%0Aline2", []string{`    note: "line1\nline2"`}},
+		{"bad=%zz", []string{"    bad: %zz"}},
+	}
+	for _, tt := range tests {
+		got := formatQuery(tt.rawQuery)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q = %q, want %q", tt.rawQuery, got, tt.expected)
+		}
+	}
+}
+
+func TestBuildRequestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		query    url.Values
+		expected string
+	}{
+		{"no query", "/cars?b=1&a=0", nil, "/cars?b=1&a=0"},
+		{"sorted", "/cars", url.Values{"page": {"2"}, "limit": {"10"}},
+			"/cars?limit=10&page=2"},
+		{"merged with path", "/cars?page=1&sort=name",
+			url.Values{"page": {"2"}, "tag": {"red", "blue"}},
+			"/cars?page=1&page=2&sort=name&tag=red&tag=blue"},
+		{"escaped", "/search",
+			url.Values{"q": {"a&b+c d"}, "note": {"line1\nline2"}},
+			"/search?note=line1%0Aline2&q=a%26b%2Bc+d"},
+	}
+	for _, tt := range tests {
+		r := &httpBaselineTestRunner{
+			t:     t,
+			suite: &Suite{opts: defaultSuiteOptions()},
+			btest: &HTTPBaselineTest{Method: "GET", Path: tt.path, Query: tt.query},
+		}
+		req := r.buildRequest()
+		if got := req.URL.RequestURI(); got != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadTestDefinitionQuery(t *testing.T) {
+	filename := writeDefinitionFile(t, `tests:
+  - name: list cars
+    method: get
+    path: /cars
+    query:
+      page: 2
+      tag: [red, blue]
+`)
+	defs, err := loadTestDefinitions(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Method != "GET" {
+		t.Fatalf("got %+v", defs)
+	}
+	expected := map[string][]string{"page": {"2"}, "tag": {"red", "blue"}}
+	if !reflect.DeepEqual(map[string][]string(defs[0].Query), expected) {
+		t.Errorf("query = %v, want %v", defs[0].Query, expected)
+	}
+}
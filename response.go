@@ -8,7 +8,9 @@ import (
 	"strings"
 )
 
-func formatResponse(r *http.Response) (string, []byte, error) {
+// formatResponse formats a response. If flushes is not nil, the body
+// is formatted in the chunks the handler flushed.
+func formatResponse(r *http.Response, fo *formatOptions, flushes []int) (string, []byte, error) {
 	// Create return string
 	var response []string
 	status := fmt.Sprintf("%s %s", r.Proto, r.Status)
@@ -23,7 +25,11 @@ func formatResponse(r *http.Response) (string, []byte, error) {
 
 	// Loop through headers
 	for _, k := range keys {
-		for _, h := range r.Header.Values(k) {
+		values := r.Header.Values(k)
+		if k == "Content-Type" {
+			values = normalizeContentTypes(values)
+		}
+		for _, h := range fo.headerValues(ArtifactResponse, k, values) {
 			response = append(response, fmt.Sprintf("%v: %v", k, h))
 		}
 	}
@@ -38,12 +44,29 @@ func formatResponse(r *http.Response) (string, []byte, error) {
 	if ctype == "" {
 		ctype = r.Header.Get("Content-type")
 	}
-	if len(body) > 0 {
-		formattedBody, err := formatBody(ctype, body)
+	// encoded bodies can only be decoded as a whole
+	var chunks []string
+	chunked := false
+	if flushes != nil && len(contentEncodings(r.Header)) == 0 {
+		chunks, chunked = fo.formatChunks(ArtifactResponse, ctype, body, flushes)
+	}
+	if chunked {
+		for i, chunk := range chunks {
+			if i > 0 {
+				response = append(response, "--- flush ---")
+			}
+			response = append(response, strings.TrimSuffix(chunk, "\n"))
+		}
+		if len(body) > 0 {
+			response = append(response, "")
+		}
+	} else if len(body) > 0 {
+		formattedBody, decoded, err := fo.formatEncodedBody(ArtifactResponse, ctype, r.Header, body)
 		if err != nil {
 			return "", nil, err
 		}
 		response = append(response, formattedBody)
+		body = decoded
 	}
 
 	// Return the request as a string
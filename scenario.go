@@ -0,0 +1,370 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ScenarioStep is one request in a Scenario. Path, Query values,
+// Host, header values and Body may reference earlier steps with templates like
+// {{steps.create.body.id}}, {{steps.create.status}} or
+// {{steps.create.headers.Location}}.
+type ScenarioStep struct {
+	Name              string
+	Method            string
+	Path              string
+	Query             url.Values
+	Host              string
+	Body              interface{} // io.Reader or string
+	Headers           map[string]string
+	Cookies           []http.Cookie
+	RequestValidator  BodyValidatorFunc
+	ResponseValidator BodyValidatorFunc
+	RecordFlushes     bool
+}
+
+// Scenario is a sequence of requests sent to the same handler and
+// database. Each step gets its own request and response baselines,
+// and a db baseline with all changes made since the scenario started.
+type Scenario struct {
+	Setup    SetupFunc
+	Teardown TeardownFunc
+	Custom   interface{}
+
+	Handler     http.Handler
+	Steps       []ScenarioStep
+	Scrubbers   []Scrubber
+	HeaderRules []HeaderRule
+	ArraySorts  []ArraySort
+
+	Db       *sqlx.DB
+	Seed     string
+	SeedFunc SeedFunc
+	Tables   []string
+}
+
+type scenarioStepResult struct {
+	status  int
+	headers http.Header
+	body    interface{}
+	rawBody string
+}
+
+var stepTemplateRegexp = regexp.MustCompile(`\{\{\s*steps\.([^.}\s]+)\.([^}\s]+)\s*\}\}`)
+
+// stepNameRegexp matches the step names stepTemplateRegexp can refer to
+var stepNameRegexp = regexp.MustCompile(`^[^.}\s]+$`)
+
+func (sr *scenarioStepResult) lookup(ref string) (interface{}, error) {
+	parts := strings.Split(ref, ".")
+	switch parts[0] {
+	case "status":
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("status has no fields")
+		}
+		return sr.status, nil
+	case "headers":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected headers.<name>")
+		}
+		if _, ok := sr.headers[http.CanonicalHeaderKey(parts[1])]; !ok {
+			return nil, fmt.Errorf("no header %s", parts[1])
+		}
+		return sr.headers.Get(parts[1]), nil
+	case "body":
+		if len(parts) == 1 {
+			return sr.rawBody, nil
+		}
+		return lookupJSONField(sr.body, parts[1:])
+	}
+	return nil, fmt.Errorf("unknown field %s", parts[0])
+}
+
+// lookupJSONField follows object keys and array indexes into a
+// decoded JSON value
+func lookupJSONField(v interface{}, fields []string) (interface{}, error) {
+	for i, field := range fields {
+		switch tv := v.(type) {
+		case map[string]interface{}:
+			fv, ok := tv[field]
+			if !ok {
+				return nil, fmt.Errorf("no field %s in body",
+					strings.Join(fields[:i+1], "."))
+			}
+			v = fv
+		case []interface{}:
+			idx, err := strconv.Atoi(field)
+			if err != nil || idx < 0 || idx >= len(tv) {
+				return nil, fmt.Errorf("no index %s in body",
+					strings.Join(fields[:i+1], "."))
+			}
+			v = tv[idx]
+		default:
+			return nil, fmt.Errorf("no field %s in body",
+				strings.Join(fields[:i+1], "."))
+		}
+	}
+	return v, nil
+}
+
+func templateValueString(v interface{}) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case json.Number:
+		return tv.String()
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(tv)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+type scenarioTemplater struct {
+	results map[string]*scenarioStepResult
+}
+
+func (st *scenarioTemplater) resolve(name string, ref string) (interface{}, error) {
+	result, ok := st.results[name]
+	if !ok {
+		return nil, fmt.Errorf("no earlier step named %q", name)
+	}
+	v, err := result.lookup(ref)
+	if err != nil {
+		return nil, fmt.Errorf("steps.%s.%s: %s", name, ref, err)
+	}
+	return v, nil
+}
+
+func (st *scenarioTemplater) expandString(s string) (string, error) {
+	var expandErr error
+	expanded := stepTemplateRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		sm := stepTemplateRegexp.FindStringSubmatch(m)
+		v, err := st.resolve(sm[1], sm[2])
+		if err != nil {
+			expandErr = err
+			return m
+		}
+		return templateValueString(v)
+	})
+	return expanded, expandErr
+}
+
+// expandValue expands templates in every string in a decoded JSON
+// value. A string that is exactly one template is replaced by the
+// referenced value so numbers and objects keep their type.
+func (st *scenarioTemplater) expandValue(v interface{}) (interface{}, error) {
+	switch tv := v.(type) {
+	case string:
+		sm := stepTemplateRegexp.FindStringSubmatch(tv)
+		if sm != nil && sm[0] == tv {
+			return st.resolve(sm[1], sm[2])
+		}
+		return st.expandString(tv)
+	case map[string]interface{}:
+		for k, fv := range tv {
+			ev, err := st.expandValue(fv)
+			if err != nil {
+				return nil, err
+			}
+			tv[k] = ev
+		}
+	case []interface{}:
+		for i, iv := range tv {
+			ev, err := st.expandValue(iv)
+			if err != nil {
+				return nil, err
+			}
+			tv[i] = ev
+		}
+	}
+	return v, nil
+}
+
+func (st *scenarioTemplater) expandBody(body interface{}) (interface{}, error) {
+	switch v := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return st.expandString(v)
+	case io.Reader:
+		data, err := ioutil.ReadAll(v)
+		if err != nil {
+			return nil, err
+		}
+		return st.expandString(string(data))
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	// keep numbers in the body exact when it is encoded again
+	d.UseNumber()
+	err = d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return st.expandValue(v)
+}
+
+// stepBaselineTest builds the HTTPBaselineTest for a step with all
+// templates expanded
+func (st *scenarioTemplater) stepBaselineTest(base HTTPBaselineTest,
+	step *ScenarioStep) (HTTPBaselineTest, error) {
+	btest := base
+	var err error
+	btest.Method = step.Method
+	btest.Cookies = step.Cookies
+	btest.RequestValidator = step.RequestValidator
+	btest.ResponseValidator = step.ResponseValidator
+	btest.RecordFlushes = step.RecordFlushes
+	btest.Path, err = st.expandString(step.Path)
+	if err != nil {
+		return btest, err
+	}
+	if step.Query != nil {
+		btest.Query = make(url.Values)
+		for k, values := range step.Query {
+			for _, v := range values {
+				expanded, err := st.expandString(v)
+				if err != nil {
+					return btest, err
+				}
+				btest.Query.Add(k, expanded)
+			}
+		}
+	}
+	btest.Host, err = st.expandString(step.Host)
+	if err != nil {
+		return btest, err
+	}
+	btest.Headers = make(map[string]string)
+	for k, v := range step.Headers {
+		btest.Headers[k], err = st.expandString(v)
+		if err != nil {
+			return btest, err
+		}
+	}
+	btest.Body, err = st.expandBody(step.Body)
+	if err != nil {
+		return btest, err
+	}
+	return btest, nil
+}
+
+func newScenarioStepResult(resp *http.Response, rawBody []byte) *scenarioStepResult {
+	result := &scenarioStepResult{
+		status:  resp.StatusCode,
+		headers: resp.Header,
+		rawBody: string(rawBody),
+	}
+	var body interface{}
+	d := json.NewDecoder(bytes.NewReader(rawBody))
+	// keep ids above 2^53 exact
+	d.UseNumber()
+	if d.Decode(&body) == nil {
+		result.body = body
+	}
+	return result
+}
+
+// validateScenarioSteps checks that a scenario has steps and that
+// their names can be used in templates and baseline names
+func validateScenarioSteps(steps []ScenarioStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("Scenario has no steps")
+	}
+	stepNames := make(map[string]bool)
+	for _, step := range steps {
+		if step.Name == "" {
+			return fmt.Errorf("Scenario step name is not provided")
+		}
+		if !stepNameRegexp.MatchString(step.Name) {
+			return fmt.Errorf("Scenario step name %q cannot contain '.', '}' or whitespace", step.Name)
+		}
+		nStepName := NormalizeTestName(step.Name)
+		if stepNames[nStepName] {
+			return fmt.Errorf("Duplicate scenario step name %q", step.Name)
+		}
+		stepNames[nStepName] = true
+	}
+	return nil
+}
+
+// RunScenario runs the steps of a scenario in order in a single
+// subtest. Baselines for each step are named after the scenario and
+// the step, e.g. testdata/<scenario>.<step>.req.txt
+func (suite *Suite) RunScenario(name string, scenario Scenario) {
+	suite.t.Run(name, func(t *testing.T) {
+		if suite.opts.parallel {
+			t.Parallel()
+		}
+		btest := HTTPBaselineTest{
+			Setup:       scenario.Setup,
+			Teardown:    scenario.Teardown,
+			Custom:      scenario.Custom,
+			Handler:     scenario.Handler,
+			Scrubbers:   scenario.Scrubbers,
+			HeaderRules: scenario.HeaderRules,
+			ArraySorts:  scenario.ArraySorts,
+			Db:          scenario.Db,
+			Seed:        scenario.Seed,
+			SeedFunc:    scenario.SeedFunc,
+			Tables:      scenario.Tables,
+		}
+		// validate before Setup runs and the db is locked
+		err := validateScenarioSteps(scenario.Steps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the scenario's baselines are named after its first
+		// request
+		btest.Method = scenario.Steps[0].Method
+		btest.Path = scenario.Steps[0].Path
+		runner := newRunner(name, t, suite, &btest)
+		scenarioPrefix := runner.baselinePrefix
+		runner.setupDb()
+
+		templater := &scenarioTemplater{
+			results: make(map[string]*scenarioStepResult),
+		}
+		for i := range scenario.Steps {
+			step := &scenario.Steps[i]
+			stepTest, err := templater.stepBaselineTest(btest, step)
+			if err != nil {
+				t.Fatalf("Error in step %q: %s", step.Name, err)
+			}
+			runner.btest = &stepTest
+			runner.baselinePrefix = scenarioPrefix + "." + NormalizeTestName(step.Name)
+			runner.checkRequestFields()
+			resp, rawRespBody := runner.runRequest()
+			templater.results[step.Name] = newScenarioStepResult(resp, rawRespBody)
+		}
+		runner.btest = &btest
+
+		if btest.Teardown != nil {
+			err := btest.Teardown(t, &btest)
+			if err != nil {
+				t.Fatalf("Teardown failed: %s", err)
+			}
+		}
+	})
+}
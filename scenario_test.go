@@ -0,0 +1,84 @@
+package httpbaselinetest
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestScenarioTemplates(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Location": []string{"/cars/1"}},
+	}
+	templater := &scenarioTemplater{results: map[string]*scenarioStepResult{
+		"create": newScenarioStepResult(resp,
+			[]byte(`{"id": 9007199254740993, "price": 1.5, "tags": ["a"], "name": "car"}`)),
+	}}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"/cars/{{steps.create.body.id}}", "/cars/9007199254740993"},
+		{"{{ steps.create.body.price }}", "1.5"},
+		{"{{steps.create.body.tags.0}}", "a"},
+		{"{{steps.create.body.tags}}", `["a"]`},
+		{"{{steps.create.body.name}}", "car"},
+		{"{{steps.create.status}}", "201"},
+		{"{{steps.create.headers.location}}", "/cars/1"},
+	}
+	for _, tt := range tests {
+		got, err := templater.expandString(tt.template)
+		if err != nil {
+			t.Errorf("%s failed: %s", tt.template, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s = %s, want %s", tt.template, got, tt.expected)
+		}
+	}
+	body, err := templater.expandBody(map[string]interface{}{"id": "{{steps.create.body.id}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := canonicalJSON(body); got != `{"id":9007199254740993}` {
+		t.Errorf("expanded body = %s", got)
+	}
+	body, err = templater.expandBody(struct {
+		ID    int64   `json:"id"`
+		Price float64 `json:"price"`
+	}{9007199254740993, 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := canonicalJSON(body); got != `{"id":9007199254740993,"price":1.5}` {
+		t.Errorf("expanded struct body = %s", got)
+	}
+}
+
+func TestValidateScenarioSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ScenarioStep
+		err   string
+	}{
+		{"valid", []ScenarioStep{{Name: "create"}, {Name: "get-car_2"}}, ""},
+		{"no steps", nil, "Scenario has no steps"},
+		{"unnamed", []ScenarioStep{{Name: "create"}, {}}, "Scenario step name is not provided"},
+		{"dot", []ScenarioStep{{Name: "create.car"}},
+			`Scenario step name "create.car" cannot contain '.', '}' or whitespace`},
+		{"space", []ScenarioStep{{Name: "create car"}},
+			`Scenario step name "create car" cannot contain '.', '}' or whitespace`},
+		{"duplicate", []ScenarioStep{{Name: "Create"}, {Name: "create"}},
+			`Duplicate scenario step name "create"`},
+	}
+	for _, tt := range tests {
+		err := validateScenarioSteps(tt.steps)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%s: got error %q, want %q", tt.name, got, tt.err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package httpbaselinetest
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Scrubber replaces values that change on every run, like generated
+// ids and timestamps, with a stable placeholder before JSON request
+// and response bodies and db rows are written to baselines.
+type Scrubber struct {
+	// Path is a JSONPath expression such as $.id,
+	// $.items[*].createdAt or $..updated_at. For db baselines
+	// the path is matched against each row.
+	Path        string
+	Placeholder string
+	// Check, if set, validates each value before it is replaced
+	// so scrubbed values are still type checked
+	Check func(value interface{}) error
+	// Artifacts limits the scrubber to some kinds of baselines.
+	// Empty means all of them.
+	Artifacts []Artifact
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// CheckRFC3339 verifies a value is an RFC3339 timestamp string
+func CheckRFC3339(value interface{}) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%v is not a string", value)
+	}
+	_, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("%q is not an RFC3339 timestamp", s)
+	}
+	return nil
+}
+
+// CheckUUID verifies a value is a UUID string
+func CheckUUID(value interface{}) error {
+	s, ok := value.(string)
+	if !ok || !uuidRegexp.MatchString(s) {
+		return fmt.Errorf("%v is not a UUID", value)
+	}
+	return nil
+}
+
+// ScrubValue replaces any value at path with placeholder
+func ScrubValue(path string, placeholder string) Scrubber {
+	return Scrubber{
+		Path:        path,
+		Placeholder: placeholder,
+	}
+}
+
+// ScrubTimestamp replaces RFC3339 timestamps at path with
+// <timestamp>
+func ScrubTimestamp(path string) Scrubber {
+	return Scrubber{
+		Path:        path,
+		Placeholder: "<timestamp>",
+		Check:       CheckRFC3339,
+	}
+}
+
+// ScrubUUID replaces UUIDs at path with <uuid>
+func ScrubUUID(path string) Scrubber {
+	return Scrubber{
+		Path:        path,
+		Placeholder: "<uuid>",
+		Check:       CheckUUID,
+	}
+}
+
+type compiledScrubber struct {
+	scrubber Scrubber
+	path     *jsonPath
+}
+
+func compileScrubbers(scrubbers []Scrubber) ([]compiledScrubber, error) {
+	compiled := make([]compiledScrubber, len(scrubbers))
+	for i := range scrubbers {
+		p, err := parseJSONPath(scrubbers[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = compiledScrubber{
+			scrubber: scrubbers[i],
+			path:     p,
+		}
+	}
+	return compiled, nil
+}
+
+func (cs *compiledScrubber) scrub(v interface{}) (interface{}, error) {
+	return cs.path.replace(v, func(value interface{}) (interface{}, error) {
+		if cs.scrubber.Check != nil {
+			err := cs.scrubber.Check(value)
+			if err != nil {
+				return nil, fmt.Errorf("scrubber %s: %s", cs.path, err)
+			}
+		}
+		return cs.scrubber.Placeholder, nil
+	})
+}
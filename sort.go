@@ -0,0 +1,128 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ArraySort sorts a JSON array whose order does not matter, such as
+// rows returned in whatever order the db picked, before baselines
+// are compared.
+type ArraySort struct {
+	// Path is a JSONPath expression for the arrays to sort. For
+	// db baselines the path is matched against each row.
+	Path string
+	// Key is the field, or dotted path of fields, of each element
+	// to sort by. Empty sorts by the element's canonical JSON.
+	Key string
+	// Artifacts limits the sort to some kinds of baselines.
+	// Empty means all of them.
+	Artifacts []Artifact
+}
+
+// SortArray sorts the arrays at path by their elements' JSON
+func SortArray(path string) ArraySort {
+	return ArraySort{
+		Path: path,
+	}
+}
+
+// SortArrayBy sorts the arrays at path by a field of their elements
+func SortArrayBy(path string, key string) ArraySort {
+	return ArraySort{
+		Path: path,
+		Key:  key,
+	}
+}
+
+type compiledArraySort struct {
+	arraySort ArraySort
+	path      *jsonPath
+}
+
+func compileArraySorts(arraySorts []ArraySort) ([]compiledArraySort, error) {
+	compiled := make([]compiledArraySort, len(arraySorts))
+	for i := range arraySorts {
+		p, err := parseJSONPath(arraySorts[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = compiledArraySort{
+			arraySort: arraySorts[i],
+			path:      p,
+		}
+	}
+	return compiled, nil
+}
+
+func canonicalJSON(v interface{}) string {
+	// maps are marshaled with sorted keys
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// compareJSONValues orders numbers numerically, strings
+// alphabetically and anything else by canonical JSON
+func compareJSONValues(a interface{}, b interface{}) int {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	}
+	return strings.Compare(canonicalJSON(a), canonicalJSON(b))
+}
+
+func (cas *compiledArraySort) sort(v interface{}) (interface{}, error) {
+	var keyFields []string
+	if cas.arraySort.Key != "" {
+		keyFields = strings.Split(cas.arraySort.Key, ".")
+	}
+	return cas.path.replace(v, func(value interface{}) (interface{}, error) {
+		l, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		keys := make(map[int]interface{})
+		canonical := make([]string, len(l))
+		for i := range l {
+			canonical[i] = canonicalJSON(l[i])
+			if keyFields != nil {
+				// elements without the key compare as null
+				keys[i], _ = lookupJSONField(l[i], keyFields)
+			}
+		}
+		indexes := make([]int, len(l))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := indexes[i], indexes[j]
+			if keyFields != nil {
+				if c := compareJSONValues(keys[a], keys[b]); c != 0 {
+					return c < 0
+				}
+			}
+			return canonical[a] < canonical[b]
+		})
+		sorted := make([]interface{}, len(l))
+		for i, index := range indexes {
+			sorted[i] = l[index]
+		}
+		return sorted, nil
+	})
+}
@@ -0,0 +1,132 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestArraySort(t *testing.T) {
+	tests := []struct {
+		name      string
+		arraySort ArraySort
+		value     string
+		expected  string
+	}{
+		{
+			name:      "canonical JSON",
+			arraySort: SortArray("$.tags"),
+			value:     `{"tags":["b",{"x":1},"a",2]}`,
+			expected:  `{"tags":["a","b",2,{"x":1}]}`,
+		},
+		{
+			name:      "numeric key",
+			arraySort: SortArrayBy("$.items", "id"),
+			value:     `{"items":[{"id":10},{"id":9},{"id":1.5}]}`,
+			expected:  `{"items":[{"id":1.5},{"id":9},{"id":10}]}`,
+		},
+		{
+			name:      "string key",
+			arraySort: SortArrayBy("$.items", "name"),
+			value:     `{"items":[{"name":"b"},{"name":"B"},{"name":"a"}]}`,
+			expected:  `{"items":[{"name":"B"},{"name":"a"},{"name":"b"}]}`,
+		},
+		{
+			name:      "nested key",
+			arraySort: SortArrayBy("$.items", "owner.id"),
+			value:     `{"items":[{"owner":{"id":2}},{"owner":{"id":1}}]}`,
+			expected:  `{"items":[{"owner":{"id":1}},{"owner":{"id":2}}]}`,
+		},
+		{
+			name:      "missing key compares as null",
+			arraySort: SortArrayBy("$.items", "id"),
+			value:     `{"items":[{"id":2},{"name":"z"},{"id":1},{"name":"a"}]}`,
+			expected:  `{"items":[{"id":1},{"id":2},{"name":"a"},{"name":"z"}]}`,
+		},
+		{
+			name:      "equal keys sort by canonical JSON",
+			arraySort: SortArrayBy("$.items", "id"),
+			value:     `{"items":[{"id":1,"v":"b"},{"id":1,"v":"a"}]}`,
+			expected:  `{"items":[{"id":1,"v":"a"},{"id":1,"v":"b"}]}`,
+		},
+		{
+			name:      "recursive descent",
+			arraySort: SortArray("$..tags"),
+			value:     `{"tags":[2,1],"cars":[{"tags":["y","x"]}]}`,
+			expected:  `{"cars":[{"tags":["x","y"]}],"tags":[1,2]}`,
+		},
+		{
+			name:      "not an array",
+			arraySort: SortArray("$.tags"),
+			value:     `{"tags":"b,a"}`,
+			expected:  `{"tags":"b,a"}`,
+		},
+	}
+	for _, tt := range tests {
+		compiled, err := compileArraySorts([]ArraySort{tt.arraySort})
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		var v interface{}
+		err = json.Unmarshal([]byte(tt.value), &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sorted, err := compiled[0].sort(v)
+		if err != nil {
+			t.Errorf("%s failed: %s", tt.name, err)
+			continue
+		}
+		if got := canonicalJSON(sorted); got != tt.expected {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.expected)
+		}
+	}
+	if _, err := compileArraySorts([]ArraySort{SortArray("tags")}); err == nil {
+		t.Error("invalid path did not fail")
+	}
+}
+
+func TestArraySortDbRows(t *testing.T) {
+	compiled, err := compileArraySorts([]ArraySort{
+		SortArray("$.tags"),
+		{Path: "$.owners", Key: "id", Artifacts: []Artifact{ArtifactResponse}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fo := &formatOptions{arraySorts: compiled}
+	rows, err := decodeRows([]string{`{"id":1,"tags":["b","a"],"owners":[{"id":2},{"id":1}]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err = fo.transformRows(rows, fo.jsonTransform(ArtifactDb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the owners sort only applies to responses
+	expected := `[{"id":1,"owners":[{"id":2},{"id":1}],"tags":["a","b"]}]`
+	if got := canonicalJSON(rows); got != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+}
+
+func TestCompareJSONValues(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{9.0, 10.0, -1},
+		{10.0, 10.0, 0},
+		{"b", "a", 1},
+		{"10", "9", -1},
+		// mixed types compare by canonical JSON
+		{"a", 1.0, -1},
+		{nil, 1.0, 1},
+		{true, false, 1},
+	}
+	for _, tt := range tests {
+		if got := compareJSONValues(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+)
+
+// transformingFormatter is implemented by built in formatters for
+// bodies made of several JSON documents, so scrubbers and array sorts
+// apply to each document
+type transformingFormatter interface {
+	formatBodyTransformed(mediaType string, params map[string]string, body []byte,
+		transform jsonTransform) (string, error)
+}
+
+// NDJSONFormatter pretty prints each line of a newline delimited JSON
+// body.
+type NDJSONFormatter struct{}
+
+func (f NDJSONFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	return f.formatBodyTransformed(mediaType, params, body, nil)
+}
+
+func (NDJSONFormatter) formatBodyTransformed(mediaType string, params map[string]string, body []byte,
+	transform jsonTransform) (string, error) {
+	var buf bytes.Buffer
+	n := 0
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		n++
+		formatted, err := formatJSON([]byte(line), transform)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %s", n, err)
+		}
+		fmt.Fprintf(&buf, "line %d:\n", n)
+		writeIndented(&buf, formatted, "  ")
+	}
+	return buf.String(), nil
+}
+
+// SSEFormatter writes a text/event-stream body as its events, with
+// JSON data pretty printed. Unknown fields are ignored.
+type SSEFormatter struct{}
+
+type sseEvent struct {
+	comments []string
+	id       *string
+	event    string
+	retry    string
+	data     []string
+	hasData  bool
+}
+
+func (f SSEFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	return f.formatBodyTransformed(mediaType, params, body, nil)
+}
+
+func (SSEFormatter) formatBodyTransformed(mediaType string, params map[string]string, body []byte,
+	transform jsonTransform) (string, error) {
+	formatted, _, err := formatSSE(body, transform, 1)
+	return formatted, err
+}
+
+// formatSSE formats the events of an event stream, numbering them from
+// first, and returns the number of events
+func formatSSE(body []byte, transform jsonTransform, first int) (string, int, error) {
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	var events []*sseEvent
+	var current *sseEvent
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			if current != nil {
+				events = append(events, current)
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = &sseEvent{}
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "":
+			current.comments = append(current.comments, value)
+		case "id":
+			current.id = &value
+		case "event":
+			current.event = value
+		case "retry":
+			current.retry = value
+		case "data":
+			current.data = append(current.data, value)
+			current.hasData = true
+		}
+		// other fields are ignored, as they are by browsers
+	}
+	if current != nil {
+		events = append(events, current)
+	}
+	var buf bytes.Buffer
+	for i, e := range events {
+		fmt.Fprintf(&buf, "event %d:\n", first+i)
+		for _, comment := range e.comments {
+			fmt.Fprintf(&buf, "  comment: %s\n", comment)
+		}
+		if e.id != nil {
+			fmt.Fprintf(&buf, "  id: %s\n", *e.id)
+		}
+		if e.event != "" {
+			fmt.Fprintf(&buf, "  event: %s\n", e.event)
+		}
+		if e.retry != "" {
+			fmt.Fprintf(&buf, "  retry: %s\n", e.retry)
+		}
+		if !e.hasData {
+			continue
+		}
+		data := strings.Join(e.data, "\n")
+		buf.WriteString("  data:\n")
+		if json.Valid([]byte(data)) {
+			formatted, err := formatJSON([]byte(data), transform)
+			if err != nil {
+				return "", 0, fmt.Errorf("event %d: %s", first+i, err)
+			}
+			data = formatted
+		}
+		writeIndented(&buf, data, "    ")
+	}
+	return buf.String(), len(events), nil
+}
+
+func writeIndented(buf *bytes.Buffer, text string, indent string) {
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		buf.WriteString(indent + line + "\n")
+	}
+}
+
+// flushRecorder records the body length each time the handler
+// flushes, so the response can be written in the chunks it was sent
+// in
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []int
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.flushes = append(fr.flushes, fr.Body.Len())
+	fr.ResponseRecorder.Flush()
+}
+
+// splitFlushes splits a body at the recorded flushes, skipping empty
+// chunks
+func splitFlushes(body []byte, flushes []int) [][]byte {
+	chunks := [][]byte{}
+	start := 0
+	for _, end := range append(flushes, len(body)) {
+		if end > len(body) {
+			end = len(body)
+		}
+		if end > start {
+			chunks = append(chunks, body[start:end])
+			start = end
+		}
+	}
+	return chunks
+}
+
+// chunkBoundary reports whether a chunk that is followed by another
+// ends at a line, or for SSE at the end of an event
+func chunkBoundary(chunk []byte, isSSE bool) bool {
+	if !isSSE {
+		return bytes.HasSuffix(chunk, []byte("\n"))
+	}
+	text := strings.ReplaceAll(string(chunk), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.HasSuffix(text, "\n\n")
+}
+
+// formatChunks formats a body in the chunks the handler flushed,
+// numbering SSE events across chunks. Only SSE, NDJSON and plain text
+// bodies are split, as other formats can't be formatted a piece at a
+// time. It returns false if the body should be formatted as a whole:
+// for other formats, binary bodies, which are only written to their
+// sidecar as a whole, and when a chunk ends partway through a line or
+// SSE event.
+func (fo *formatOptions) formatChunks(artifact Artifact, contentType string, body []byte,
+	flushes []int) ([]string, bool) {
+	formatter, mediaType, _ := fo.lookupFormatter(contentType)
+	switch formatter.(type) {
+	case SSEFormatter, NDJSONFormatter:
+	case nil:
+		if isBinaryBody(mediaType, body) {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	_, isSSE := formatter.(SSEFormatter)
+	transform := fo.jsonTransform(artifact)
+	chunks := []string{}
+	nextEvent := 1
+	split := splitFlushes(body, flushes)
+	for i, chunk := range split {
+		if i < len(split)-1 && !chunkBoundary(chunk, isSSE) {
+			return nil, false
+		}
+		var formatted string
+		var err error
+		if isSSE {
+			var n int
+			formatted, n, err = formatSSE(chunk, transform, nextEvent)
+			nextEvent += n
+		} else {
+			formatted, err = fo.formatBody(artifact, contentType, chunk)
+		}
+		if err != nil {
+			return nil, false
+		}
+		chunks = append(chunks, formatted)
+	}
+	return chunks, true
+}
@@ -0,0 +1,153 @@
+package httpbaselinetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFlushes(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		flushes  []int
+		expected []string
+	}{
+		{"no flushes", "abc", nil, []string{"abc"}},
+		{"empty body", "", []int{0, 0}, []string{}},
+		{"flushes", "abcdef", []int{2, 4}, []string{"ab", "cd", "ef"}},
+		{"flush at end", "abcdef", []int{3, 6}, []string{"abc", "def"}},
+		{"repeated flushes", "abcdef", []int{0, 3, 3}, []string{"abc", "def"}},
+		{"flush past end", "abc", []int{5}, []string{"abc"}},
+	}
+	for _, tt := range tests {
+		chunks := []string{}
+		for _, chunk := range splitFlushes([]byte(tt.body), tt.flushes) {
+			chunks = append(chunks, string(chunk))
+		}
+		if !reflect.DeepEqual(chunks, tt.expected) {
+			t.Errorf("%s: got %q, want %q", tt.name, chunks, tt.expected)
+		}
+	}
+}
+
+func TestFormatSSE(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		first    int
+		expected string
+		n        int
+	}{
+		{
+			name:     "empty",
+			body:     "",
+			first:    1,
+			expected: "",
+			n:        0,
+		},
+		{
+			name:  "fields",
+			body:  ": hello\nid: 1\nevent: tick\nretry: 1000\ndata: plain\n\n",
+			first: 1,
+			expected: "event 1:\n  comment: hello\n  id: 1\n  event: tick\n" +
+				"  retry: 1000\n  data:\n    plain\n",
+			n: 1,
+		},
+		{
+			name:     "json data",
+			body:     "data: {\"b\":1,\ndata: \"a\":2}\n\n",
+			first:    1,
+			expected: "event 1:\n  data:\n    {\n      \"a\": 2,\n      \"b\": 1\n    }\n",
+			n:        1,
+		},
+		{
+			name:     "crlf and empty id",
+			body:     "id\r\ndata:x\r\n\r\ndata: y",
+			first:    1,
+			expected: "event 1:\n  id: \n  data:\n    x\nevent 2:\n  data:\n    y\n",
+			n:        2,
+		},
+		{
+			name:     "unknown fields",
+			body:     "foo: bar\ndata: a\n\n",
+			first:    1,
+			expected: "event 1:\n  data:\n    a\n",
+			n:        1,
+		},
+		{
+			name:     "numbered from first",
+			body:     "data: a\n\ndata: b\n\n",
+			first:    3,
+			expected: "event 3:\n  data:\n    a\nevent 4:\n  data:\n    b\n",
+			n:        2,
+		},
+	}
+	for _, tt := range tests {
+		formatted, n, err := formatSSE([]byte(tt.body), nil, tt.first)
+		if err != nil {
+			t.Errorf("%s: failed: %s", tt.name, err)
+			continue
+		}
+		if formatted != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.name, formatted, tt.expected)
+		}
+		if n != tt.n {
+			t.Errorf("%s: got %d events, want %d", tt.name, n, tt.n)
+		}
+	}
+}
+
+func TestFormatChunks(t *testing.T) {
+	fo := &formatOptions{binaryBodies: make(map[Artifact]*binaryBody)}
+	body := []byte("data: a\n\ndata: b\n\ndata: c\n\n")
+	chunks, ok := fo.formatChunks(ArtifactResponse, "text/event-stream", body, []int{9})
+	if !ok {
+		t.Fatal("SSE body was not split")
+	}
+	expected := []string{
+		"event 1:\n  data:\n    a\n",
+		"event 2:\n  data:\n    b\nevent 3:\n  data:\n    c\n",
+	}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("got %q, want %q", chunks, expected)
+	}
+	binary := []byte{0x89, 'P', 'N', 'G', 0, 1, 2, 3}
+	_, ok = fo.formatChunks(ArtifactResponse, "image/png", binary, []int{4})
+	if ok {
+		t.Error("binary body was split")
+	}
+	if len(fo.binaryBodies) != 0 {
+		t.Errorf("binary formatChunks kept %d binary bodies", len(fo.binaryBodies))
+	}
+}
+
+func TestFormatChunksWhole(t *testing.T) {
+	fo := &formatOptions{binaryBodies: make(map[Artifact]*binaryBody)}
+	tests := []struct {
+		contentType string
+		body        string
+		flushes     []int
+		split       bool
+	}{
+		{"text/plain", "one\ntwo\n", []int{4}, true},
+		{"application/x-ndjson", "{\"a\":1}\n{\"b\":2}\n", []int{8}, true},
+		// flushed partway through a line
+		{"application/x-ndjson", "{\"a\":1}\n{\"b\":2}\n", []int{4}, false},
+		{"application/x-ndjson", "123\n", []int{2}, false},
+		{"text/plain", "one\ntwo\n", []int{2}, false},
+		{"text/event-stream", "data: a\n\ndata: b\n\n", []int{9}, true},
+		{"text/event-stream", "data: a\r\n\r\ndata: b\r\n\r\n", []int{11}, true},
+		{"text/event-stream", "data: hello\n\ndata: b\n\n", []int{9}, false},
+		// flushed between the lines of an event
+		{"text/event-stream", "id: 1\ndata: a\n\n", []int{6}, false},
+		{"application/json", `{"items":[1,2]}`, []int{12}, false},
+		{"application/xml", "<a><b/></a>", []int{3}, false},
+		{"application/x-www-form-urlencoded", "a=1&b=2", []int{4}, false},
+	}
+	for _, tt := range tests {
+		_, ok := fo.formatChunks(ArtifactResponse, tt.contentType, []byte(tt.body), tt.flushes)
+		if ok != tt.split {
+			t.Errorf("%s %q split = %v, want %v", tt.contentType, tt.body, ok, tt.split)
+		}
+	}
+}
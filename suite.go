@@ -3,14 +3,16 @@ package httpbaselinetest
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -18,15 +20,50 @@ import (
 )
 
 type Suite struct {
-	t           *testing.T
-	baselineDir string
+	t    *testing.T
+	opts suiteOptions
+
+	// protects dbLocks, baselinePrefixes, touchedPaths and results
+	// when running in parallel
+	mu               sync.Mutex
+	dbLocks          map[*sqlx.DB]*sync.Mutex
+	baselinePrefixes map[string]string
+	touchedPaths     map[string]bool
+	results          []*testResult
+	// serializes baseline and seed file access
+	fileLock sync.RWMutex
 }
 
-func NewDefaultSuite(t *testing.T) *Suite {
-	return &Suite{
-		t:           t,
-		baselineDir: "testdata",
+func NewSuite(t *testing.T, opts ...SuiteOption) *Suite {
+	o := defaultSuiteOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.seedDir == "" {
+		o.seedDir = o.baselineDir
+	}
+	suite := &Suite{
+		t:                t,
+		opts:             o,
+		dbLocks:          make(map[*sqlx.DB]*sync.Mutex),
+		baselinePrefixes: make(map[string]string),
+		touchedPaths:     make(map[string]bool),
+	}
+	if o.rebaselineMode == RebaselineFromEnv {
+		if _, _, err := doRebaseline(); err != nil {
+			t.Fatal(err)
+		}
 	}
+	suite.registerOrphanCheck()
+	t.Cleanup(suite.finishOrphanCheck)
+	if o.jsonReportPath != "" || o.junitReportPath != "" {
+		t.Cleanup(suite.writeReports)
+	}
+	return suite
+}
+
+func NewDefaultSuite(t *testing.T) *Suite {
+	return NewSuite(t)
 }
 
 type SetupFunc func(testName string, baselineTest *HTTPBaselineTest) error
@@ -41,12 +78,20 @@ type HTTPBaselineTest struct {
 	Handler           http.Handler
 	Method            string
 	Path              string
+	Query             url.Values // added to the query string of Path
 	Host              string
 	Body              interface{} // io.Reader or string
 	Headers           map[string]string
 	Cookies           []http.Cookie
 	RequestValidator  BodyValidatorFunc
 	ResponseValidator BodyValidatorFunc
+	Scrubbers         []Scrubber
+	HeaderRules       []HeaderRule
+	ArraySorts        []ArraySort
+	// RecordFlushes writes SSE, NDJSON and plain text response
+	// bodies in the chunks the handler flushed, separated by
+	// --- flush ---
+	RecordFlushes bool
 
 	Db       *sqlx.DB
 	Seed     string
@@ -55,19 +100,39 @@ type HTTPBaselineTest struct {
 }
 
 type httpBaselineTestRunner struct {
-	testName         string
-	suite            *Suite
-	btest            *HTTPBaselineTest
-	t                *testing.T
-	baselineReqPath  string
-	baselineRespPath string
-	baselineDbPath   string
-	seedPath         string
-	dbTableInfo      *dbTableInfo
+	testName       string
+	suite          *Suite
+	btest          *HTTPBaselineTest
+	t              *testing.T
+	baselinePrefix string
+	seedPath       string
+	dbTableInfo    *dbTableInfo
+	formatOptions  *formatOptions
+	// the db locked before Setup, if any
+	lockedDb *sqlx.DB
+	// nil unless the suite writes reports
+	result *testResult
 }
 
 func newRunner(testName string, t *testing.T, suite *Suite,
 	btest *HTTPBaselineTest) httpBaselineTestRunner {
+	result := suite.startTestResult(t)
+	if btest.Setup == nil {
+		btest.Setup = suite.opts.setup
+	}
+	if btest.Teardown == nil {
+		btest.Teardown = suite.opts.teardown
+	}
+	if btest.Db == nil {
+		btest.Db = suite.opts.db
+	}
+	// lock the db before Setup so that Setup can use it without
+	// interfering with other parallel tests
+	var lockedDb *sqlx.DB
+	if suite.opts.parallel && btest.Db != nil {
+		lockedDb = btest.Db
+		t.Cleanup(suite.lockDb(lockedDb))
+	}
 	if btest.Setup != nil {
 		err := btest.Setup(testName, btest)
 		if err != nil {
@@ -77,28 +142,66 @@ func newRunner(testName string, t *testing.T, suite *Suite,
 	if btest.Handler == nil {
 		t.Fatal("Handler is nil")
 	}
-	if btest.Method == "" {
-		t.Fatal("Method is not provided")
+	baselinePath := suite.opts.baselinePathFunc(BaselinePathInfo{
+		TestName: testName,
+		FullName: t.Name(),
+		Method:   btest.Method,
+		Path:     btest.Path,
+	})
+	if baselinePath == "" {
+		t.Fatal("Baseline path is empty")
 	}
-	if btest.Path == "" {
-		t.Fatal("Path is not provided")
+	nPathPrefix := path.Join(suite.opts.baselineDir, baselinePath)
+	if suite.opts.parallel {
+		if other, ok := suite.claimBaselinePrefix(nPathPrefix, testName); !ok {
+			t.Fatalf("Baseline %s is already used by test %q", nPathPrefix, other)
+		}
 	}
-	nPathPrefix := path.Join(suite.baselineDir, NormalizeTestName(testName))
 
 	var seedPath string
 	if btest.Seed != "" {
-		seedPath = path.Join(suite.baselineDir, btest.Seed)
+		seedPath = path.Join(suite.opts.seedDir, btest.Seed)
+		suite.touchPath(seedPath)
 	}
-	return httpBaselineTestRunner{
-		testName:         testName,
-		suite:            suite,
-		btest:            btest,
-		t:                t,
-		baselineReqPath:  nPathPrefix + ".req.txt",
-		baselineRespPath: nPathPrefix + ".resp.txt",
-		baselineDbPath:   nPathPrefix + ".db.json",
-		seedPath:         seedPath,
-		dbTableInfo:      &dbTableInfo{},
+	fo, err := newFormatOptions(suite, btest)
+	if err != nil {
+		t.Fatalf("Invalid format options: %s", err)
+	}
+	r := httpBaselineTestRunner{
+		testName:       testName,
+		suite:          suite,
+		btest:          btest,
+		t:              t,
+		baselinePrefix: nPathPrefix,
+		seedPath:       seedPath,
+		dbTableInfo:    &dbTableInfo{},
+		formatOptions:  fo,
+		lockedDb:       lockedDb,
+		result:         result,
+	}
+	return r
+}
+
+func (r *httpBaselineTestRunner) splitBaselinePath(artifact Artifact) string {
+	switch artifact {
+	case ArtifactRequest:
+		return r.baselinePrefix + ".req.txt"
+	case ArtifactResponse:
+		return r.baselinePrefix + ".resp.txt"
+	}
+	return r.baselinePrefix + ".db.json"
+}
+
+func (r *httpBaselineTestRunner) combinedBaselinePath() string {
+	return r.baselinePrefix + ".txtar"
+}
+
+func (r *httpBaselineTestRunner) checkRequestFields() {
+	if r.btest.Method == "" {
+		r.t.Fatal("Method is not provided")
+	}
+	if r.btest.Path == "" {
+		r.t.Fatal("Path is not provided")
 	}
 }
 
@@ -110,12 +213,18 @@ func NormalizeTestName(name string) string {
 	return string(tbytes)
 }
 
-func formatJSON(body []byte) (string, error) {
+func formatJSON(body []byte, transform jsonTransform) (string, error) {
 	var v interface{}
 	err := json.Unmarshal(body, &v)
 	if err != nil {
 		return "", err
 	}
+	if transform != nil {
+		v, err = transform(v)
+		if err != nil {
+			return "", err
+		}
+	}
 	// use encoder to add trailing newline
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -127,26 +236,147 @@ func formatJSON(body []byte) (string, error) {
 	return buf.String(), nil
 }
 
-func formatBody(contentType string, body []byte) (string, error) {
-	if contentType == "application/json" {
-		return formatJSON(body)
+// doRebaseline parses the REBASELINE environment variable, a comma
+// separated list of a mode and/or artifacts, e.g. "1", "missing",
+// "resp,db" or "failed,resp". A nil artifact set means all
+// artifacts. Unknown values are an error so a typo can't rewrite
+// every baseline.
+func doRebaseline() (RebaselineMode, map[Artifact]bool, error) {
+	env := strings.ToLower(os.Getenv("REBASELINE"))
+	if env == "" {
+		return RebaselineNever, nil, nil
+	}
+	mode := RebaselineAlways
+	var artifacts map[Artifact]bool
+	for _, token := range strings.Split(env, ",") {
+		token = strings.TrimSpace(token)
+		if artifact, ok := parseArtifact(token); ok {
+			if artifacts == nil {
+				artifacts = make(map[Artifact]bool)
+			}
+			artifacts[artifact] = true
+			continue
+		}
+		switch token {
+		case "1", "true":
+		case "missing":
+			mode = RebaselineMissing
+		case "failed":
+			mode = RebaselineFailed
+		default:
+			return RebaselineNever, nil, fmt.Errorf(
+				"invalid REBASELINE value %q: expected a comma separated list of "+
+					"1, true, missing, failed, req, request, resp, response or db", token)
+		}
 	}
-	return string(body), nil
+	return mode, artifacts, nil
+}
 
+func (suite *Suite) rebaselineMode() (RebaselineMode, map[Artifact]bool) {
+	artifacts := suite.opts.rebaselineArtifacts
+	if suite.opts.rebaselineMode == RebaselineFromEnv {
+		// REBASELINE was validated by NewSuite
+		mode, envArtifacts, _ := doRebaseline()
+		if envArtifacts != nil {
+			artifacts = envArtifacts
+		}
+		return mode, artifacts
+	}
+	return suite.opts.rebaselineMode, artifacts
 }
 
-func doRebaseline() bool {
-	return os.Getenv("REBASELINE") != ""
+// rebaselineModeFor returns the rebaseline mode for one kind of
+// artifact. Artifacts that were not selected are only compared.
+func (suite *Suite) rebaselineModeFor(artifact Artifact) RebaselineMode {
+	mode, artifacts := suite.rebaselineMode()
+	if artifacts != nil && !artifacts[artifact] {
+		return RebaselineNever
+	}
+	return mode
 }
 
-func (r *httpBaselineTestRunner) assertBaselineEquality(expectedPath string, formatted string) {
-	expected, err := ioutil.ReadFile(expectedPath)
+// readBaseline returns the baseline for an artifact and a label for
+// where it was read from. Both layouts are read so that a suite can
+// switch layouts; the configured layout is tried first.
+func (r *httpBaselineTestRunner) readBaseline(artifact Artifact) (string, string, bool) {
+	r.suite.fileLock.RLock()
+	defer r.suite.fileLock.RUnlock()
+	readSplit := func() (string, string, bool) {
+		path := r.splitBaselinePath(artifact)
+		data, ok := r.readBaselineFile(path)
+		return data, path, ok
+	}
+	readCombined := func() (string, string, bool) {
+		path := r.combinedBaselinePath()
+		data, ok := r.readBaselineFile(path)
+		if !ok {
+			return "", path, false
+		}
+		section := artifactSection(artifact)
+		data, ok = parseBaselineArchive(data).section(section)
+		return data, path + " [" + section + "]", ok
+	}
+	first, second := readSplit, readCombined
+	if r.suite.opts.baselineFormat == BaselineCombined {
+		first, second = readCombined, readSplit
+	}
+	data, label, ok := first()
+	if ok {
+		return data, label, true
+	}
+	if data, otherLabel, ok := second(); ok {
+		return data, otherLabel, true
+	}
+	// report missing baselines in the configured layout
+	return data, label, false
+}
+
+func (r *httpBaselineTestRunner) readBaselineFile(path string) (string, bool) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false
+	}
 	if err != nil {
-		r.t.Fatalf("Error reading baseline %s: %s", expectedPath, err)
+		r.t.Fatalf("Error reading baseline %s: %s", path, err)
+	}
+	r.suite.touchPath(path)
+	return string(data), true
+}
+
+// writeBaseline writes the baseline for an artifact using the
+// configured layout. Other sections of a combined baseline are kept.
+func (r *httpBaselineTestRunner) writeBaseline(artifact Artifact, formatted string) {
+	if r.suite.opts.baselineFormat != BaselineCombined {
+		path := r.splitBaselinePath(artifact)
+		r.suite.touchPath(path)
+		r.writeFile(path, []byte(formatted))
+		return
+	}
+	path := r.combinedBaselinePath()
+	r.suite.touchPath(path)
+	// combined baselines have no escaping
+	if line, ok := findSectionHeader(formatted); ok {
+		r.t.Fatalf("Cannot write %s baseline to %s: the line %q would start a new section; "+
+			"use the split baseline format or a scrubber", artifactSection(artifact), path, line)
+	}
+	r.suite.fileLock.Lock()
+	defer r.suite.fileLock.Unlock()
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		r.t.Fatalf("Error reading baseline %s: %s", path, err)
 	}
+	archive := parseBaselineArchive(string(data))
+	archive.setSection(artifactSection(artifact), formatted)
+	r.writeFileLocked(path, []byte(archive.format()))
+}
+
+// baselineDiff returns the unified diff between the expected
+// baseline and formatted, or "" if they are the same
+func (r *httpBaselineTestRunner) baselineDiff(expectedPath string, expected string, formatted string) string {
+	// sections of a combined baseline always end in a newline
 	diff := difflib.UnifiedDiff{
-		A:        difflib.SplitLines(string(expected)),
-		B:        difflib.SplitLines(formatted),
+		A:        difflib.SplitLines(ensureTrailingNewline(expected)),
+		B:        difflib.SplitLines(ensureTrailingNewline(formatted)),
 		FromFile: expectedPath + " (expected)",
 		ToFile:   "actual",
 		Eol:      "\n",
@@ -155,13 +385,64 @@ func (r *httpBaselineTestRunner) assertBaselineEquality(expectedPath string, for
 	if err != nil {
 		r.t.Fatalf("Error generating diff: %s", err)
 	}
-	if diffstr != "" {
-		r.t.Error("Request Difference")
-		r.t.Log("\n" + diffstr)
+	return diffstr
+}
+
+// assertBaselineEquality fails the test if formatted doesn't match
+// the expected baseline, and returns a summary of the changes and
+// the reported diff
+func (r *httpBaselineTestRunner) assertBaselineEquality(artifact Artifact, expectedPath string,
+	expected string, formatted string) (string, string) {
+	diffstr := r.baselineDiff(expectedPath, expected, formatted)
+	if diffstr == "" {
+		return "", ""
+	}
+	if r.suite.opts.diffFormat == DiffSemantic {
+		semantic, ok := r.semanticDiff(artifact, expectedPath, expected, formatted)
+		if ok && semantic != "" {
+			diffstr = semantic
+		}
+	}
+	summary := summarizeMismatch(artifact, expected, formatted)
+	r.t.Errorf("%s baseline mismatch: %s", artifactSection(artifact), summary)
+	r.t.Log("\n" + diffstr)
+	return summary, diffstr
+}
+
+// claimBaselinePrefix records that testName uses the baseline
+// prefix. If another test already claimed it, that test's name is
+// returned with false.
+func (suite *Suite) claimBaselinePrefix(prefix string, testName string) (string, bool) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	if other, ok := suite.baselinePrefixes[prefix]; ok {
+		return other, false
 	}
+	suite.baselinePrefixes[prefix] = testName
+	return testName, true
+}
+
+// lockDb serializes tests sharing a database connection so that
+// seeding and pg_stat_xact_user_tables snapshots do not overlap.
+func (suite *Suite) lockDb(db *sqlx.DB) func() {
+	suite.mu.Lock()
+	l, ok := suite.dbLocks[db]
+	if !ok {
+		l = &sync.Mutex{}
+		suite.dbLocks[db] = l
+	}
+	suite.mu.Unlock()
+	l.Lock()
+	return l.Unlock
 }
 
 func (r *httpBaselineTestRunner) writeFile(path string, formattedData []byte) {
+	r.suite.fileLock.Lock()
+	defer r.suite.fileLock.Unlock()
+	r.writeFileLocked(path, formattedData)
+}
+
+func (r *httpBaselineTestRunner) writeFileLocked(path string, formattedData []byte) {
 	dir := filepath.Dir(path)
 	err := os.MkdirAll(dir, 0755)
 	if err != nil {
@@ -174,75 +455,146 @@ func (r *httpBaselineTestRunner) writeFile(path string, formattedData []byte) {
 	}
 }
 
-func (suite *Suite) Run(name string, btest HTTPBaselineTest) {
-	suite.t.Run(name, func(t *testing.T) {
-		// Should probably have some way of indicating if the
-		// test should be t.Parallel()
-		runner := newRunner(name, t, suite, &btest)
+// setupDb seeds the db and records its initial state. The db is
+// released when the test finishes.
+func (r *httpBaselineTestRunner) setupDb() {
+	db := r.btest.Db
+	if db == nil {
+		return
+	}
+	// Setup may have replaced the db locked before it ran
+	if r.suite.opts.parallel && db != r.lockedDb {
+		r.t.Cleanup(r.suite.lockDb(db))
+	}
+	// make sure we close the db connection after the test,
+	// unless it is shared by the suite
+	if db != r.suite.opts.db {
+		r.t.Cleanup(func() {
+			db.Close()
+		})
+	}
+	r.dbTestSetup()
+}
 
-		if btest.Db != nil {
-			runner.dbTestSetup()
-			// make sure we close the db connection after
-			// the test
-			defer btest.Db.Close()
+func (r *httpBaselineTestRunner) checkBaseline(artifact Artifact, formatted string) {
+	formatted = r.formatOptions.unescapePlaceholders(formatted)
+	formatted = r.formatOptions.aliasUUIDs(formatted)
+	// the baseline compares the binary body's hash; the body itself
+	// is only written when rebaselining or if it is missing
+	binary := r.formatOptions.takeBinaryBody(artifact)
+	if binary != nil {
+		r.suite.touchPath(r.sidecarPath(artifact, binary))
+	}
+	mode := r.suite.rebaselineModeFor(artifact)
+	expected, expectedPath, ok := r.readBaseline(artifact)
+	written := artifactRebaselined
+	if !ok {
+		if mode == RebaselineMissing || mode == RebaselineFailed {
+			r.t.Logf("Creating missing baseline %s", expectedPath)
+			mode = RebaselineAlways
+			written = artifactCreated
 		}
-
-		req := runner.buildRequest()
-		formattedReq, rawReqBody, err := formatRequest(req)
-		if err != nil {
-			t.Fatalf("Error formatting request: %s", err)
+		if mode != RebaselineAlways {
+			r.recordArtifact(artifact, expectedPath, artifactFailed, "missing baseline", "")
+			r.t.Fatalf("Error reading baseline %s: %s", expectedPath, os.ErrNotExist)
 		}
-		if btest.RequestValidator != nil {
-			err = btest.RequestValidator(rawReqBody)
-			if err != nil {
-				t.Errorf("Error validating request: %s", err)
-			}
+	}
+	// matchers in the existing baseline are kept when
+	// rebaselining if the new value still matches
+	expected, preserved := resolveMatchers(expected, formatted)
+	switch mode {
+	case RebaselineAlways:
+		r.writeBaseline(artifact, preserved)
+		r.writeSidecar(artifact, binary)
+		r.recordArtifact(artifact, expectedPath, written, "", "")
+	case RebaselineFailed:
+		if diffstr := r.baselineDiff(expectedPath, expected, formatted); diffstr != "" {
+			r.t.Logf("Rebaselining %s", expectedPath)
+			r.writeBaseline(artifact, preserved)
+			r.writeSidecar(artifact, binary)
+			r.recordArtifact(artifact, expectedPath, artifactRebaselined,
+				summarizeMismatch(artifact, expected, formatted), diffstr)
+		} else {
+			r.writeMissingSidecar(artifact, binary)
+			r.recordArtifact(artifact, expectedPath, artifactPassed, "", "")
 		}
-		if doRebaseline() {
-			runner.writeFile(runner.baselineReqPath,
-				[]byte(formattedReq))
+	default:
+		summary, diffstr := r.assertBaselineEquality(artifact, expectedPath, expected, formatted)
+		if diffstr != "" {
+			r.recordArtifact(artifact, expectedPath, artifactFailed, summary, diffstr)
 		} else {
-			runner.assertBaselineEquality(runner.baselineReqPath,
-				formattedReq)
+			if mode == RebaselineMissing {
+				r.writeMissingSidecar(artifact, binary)
+			}
+			r.recordArtifact(artifact, expectedPath, artifactPassed, "", "")
 		}
+	}
+}
 
-		recorder := httptest.NewRecorder()
-		btest.Handler.ServeHTTP(recorder, req)
-		formattedResp, rawRespBody, err := formatResponse(recorder.Result())
+// runRequest sends the request for the current btest to its handler
+// and checks the request, response and db baselines. The recorded
+// response is returned along with its body.
+func (r *httpBaselineTestRunner) runRequest() (*http.Response, []byte) {
+	t := r.t
+	btest := r.btest
+	req := r.buildRequest()
+	formattedReq, rawReqBody, err := formatRequest(req, r.formatOptions)
+	if err != nil {
+		t.Fatalf("Error formatting request: %s", err)
+	}
+	if btest.RequestValidator != nil {
+		err = btest.RequestValidator(rawReqBody)
+		if err != nil {
+			t.Errorf("Error validating request: %s", err)
+		}
+	}
+	r.checkBaseline(ArtifactRequest, formattedReq)
+
+	recorder := newFlushRecorder()
+	btest.Handler.ServeHTTP(recorder, req)
+	resp := recorder.Result()
+	var flushes []int
+	if btest.RecordFlushes {
+		flushes = append([]int{}, recorder.flushes...)
+	}
+	formattedResp, rawRespBody, err := formatResponse(resp, r.formatOptions, flushes)
+	if err != nil {
+		t.Fatalf("Error formatting response: %s", err)
+	}
+	if btest.ResponseValidator != nil {
+		err := btest.ResponseValidator(rawRespBody)
 		if err != nil {
-			t.Fatalf("Error formatting response: %s", err)
+			t.Errorf("Error validating response: %s", err)
 		}
-		if btest.ResponseValidator != nil {
-			err := btest.ResponseValidator(rawRespBody)
+	}
+	r.checkBaseline(ArtifactResponse, formattedResp)
+
+	if btest.Db != nil {
+		fullDbBaseline := r.generateDbBaseline()
+		if btest.Tables != nil {
+			formattedDb, err := formatDb(fullDbBaseline, r.formatOptions)
 			if err != nil {
-				t.Errorf("Error validating response: %s", err)
+				t.Fatalf("Cannot format db baseline: %s", err)
 			}
-		}
-		if doRebaseline() {
-			runner.writeFile(runner.baselineRespPath,
-				[]byte(formattedResp))
+			r.checkBaseline(ArtifactDb, string(formattedDb))
 		} else {
-			runner.assertBaselineEquality(runner.baselineRespPath,
-				formattedResp)
-		}
-
-		if btest.Db != nil {
-			fullDbBaseline := runner.generateDbBaseline()
-			if btest.Tables != nil {
-				formattedDb, err := formatDb(fullDbBaseline)
-				if err != nil {
-					t.Fatalf("Cannot format db baseline: %s", err)
-				}
-				if doRebaseline() {
-					runner.writeFile(runner.baselineDbPath, formattedDb)
-				} else {
-					runner.assertBaselineEquality(runner.baselineDbPath,
-						string(formattedDb))
-				}
-			} else {
-				runner.assertNoDbChanges(fullDbBaseline)
-			}
+			r.assertNoDbChanges(fullDbBaseline)
 		}
+	}
+
+	return resp, rawRespBody
+}
+
+func (suite *Suite) Run(name string, btest HTTPBaselineTest) {
+	suite.t.Run(name, func(t *testing.T) {
+		if suite.opts.parallel {
+			t.Parallel()
+		}
+		runner := newRunner(name, t, suite, &btest)
+		runner.checkRequestFields()
+		runner.setupDb()
+
+		runner.runRequest()
 
 		if btest.Teardown != nil {
 			err := btest.Teardown(t, &btest)
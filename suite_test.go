@@ -0,0 +1,246 @@
+package httpbaselinetest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestDoRebaseline(t *testing.T) {
+	tests := []struct {
+		env       string
+		mode      RebaselineMode
+		artifacts map[Artifact]bool
+		wantErr   bool
+	}{
+		{env: "", mode: RebaselineNever},
+		{env: "1", mode: RebaselineAlways},
+		{env: "true", mode: RebaselineAlways},
+		{env: "missing", mode: RebaselineMissing},
+		{env: "FAILED", mode: RebaselineFailed},
+		{env: "resp,db", mode: RebaselineAlways,
+			artifacts: map[Artifact]bool{ArtifactResponse: true, ArtifactDb: true}},
+		{env: "failed, request", mode: RebaselineFailed,
+			artifacts: map[Artifact]bool{ArtifactRequest: true}},
+		{env: "rsp", wantErr: true},
+		{env: "yes", wantErr: true},
+		{env: "resp,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv("REBASELINE", tt.env)
+			mode, artifacts, err := doRebaseline()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got mode %d", mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode != tt.mode {
+				t.Errorf("mode = %d, want %d", mode, tt.mode)
+			}
+			if !reflect.DeepEqual(artifacts, tt.artifacts) {
+				t.Errorf("artifacts = %v, want %v", artifacts, tt.artifacts)
+			}
+		})
+	}
+}
+
+// rebaselineHelperDir is set when TestRebaselineMissingMismatch runs
+// the test binary to check a baseline that does not match
+const rebaselineHelperDir = "HTTPBASELINETEST_HELPER_DIR"
+
+func rebaselineModeHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+}
+
+func TestRebaselineModes(t *testing.T) {
+	dir := t.TempDir()
+	resp := filepath.Join(dir, "get_car.resp.txt")
+	run := func(mode RebaselineMode, body string) bool {
+		return t.Run("suite", func(t *testing.T) {
+			bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(mode))
+			bts.Run("get car", HTTPBaselineTest{
+				Handler: rebaselineModeHandler(body),
+				Method:  "GET",
+				Path:    "/car",
+			})
+		})
+	}
+	readResp := func() (string, time.Time) {
+		t.Helper()
+		data, err := ioutil.ReadFile(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), info.ModTime()
+	}
+
+	if !run(RebaselineMissing, "honda\n") {
+		t.Fatal("missing baselines were not created")
+	}
+	for _, name := range []string{"get_car.req.txt", "get_car.resp.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not created: %s", name, err)
+		}
+	}
+
+	// matching baselines are left alone
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(resp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []RebaselineMode{RebaselineMissing, RebaselineFailed} {
+		if !run(mode, "honda\n") {
+			t.Fatalf("mode %d failed with matching baselines", mode)
+		}
+		if _, modTime := readResp(); !modTime.Equal(old) {
+			t.Errorf("mode %d rewrote a matching baseline", mode)
+		}
+	}
+
+	// mismatching baselines are only rewritten by RebaselineFailed
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRebaselineMissingMismatch$")
+	cmd.Env = append(os.Environ(), rebaselineHelperDir+"="+dir)
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Errorf("RebaselineMissing passed with a mismatching baseline:\n%s", out)
+	}
+	if data, modTime := readResp(); !modTime.Equal(old) || strings.Contains(data, "toyota") {
+		t.Errorf("RebaselineMissing rewrote a mismatching baseline:\n%s", data)
+	}
+	if !run(RebaselineFailed, "toyota\n") {
+		t.Fatal("RebaselineFailed failed with a mismatching baseline")
+	}
+	if data, _ := readResp(); !strings.Contains(data, "toyota") {
+		t.Errorf("RebaselineFailed did not rewrite the baseline:\n%s", data)
+	}
+}
+
+func TestRebaselineMissingMismatch(t *testing.T) {
+	dir := os.Getenv(rebaselineHelperDir)
+	if dir == "" {
+		t.Skip("run by TestRebaselineModes")
+	}
+	bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(RebaselineMissing))
+	bts.Run("get car", HTTPBaselineTest{
+		Handler: rebaselineModeHandler("toyota\n"),
+		Method:  "GET",
+		Path:    "/car",
+	})
+}
+
+// parallelHelperDir is set when TestParallelNameCollision runs the
+// test binary to run tests whose baselines collide
+const parallelHelperDir = "HTTPBASELINETEST_PARALLEL_DIR"
+
+// maxParallel runs tests in a parallel suite using db as the default
+// db, and returns the most that ran at once
+func maxParallel(t *testing.T, dir string, db *sqlx.DB) int {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	setup := func(testName string, btest *HTTPBaselineTest) error {
+		if btest.Db != db {
+			return fmt.Errorf("Db = %p, want the default db", btest.Db)
+		}
+		// the fake db is never queried: the lock is taken before
+		// Setup, and nothing uses the db once it is removed
+		btest.Db = nil
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	teardown := func(t *testing.T, btest *HTTPBaselineTest) error {
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+	t.Run(fmt.Sprintf("db %t", db != nil), func(t *testing.T) {
+		bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(RebaselineAlways),
+			WithParallel(), WithDefaultDb(db),
+			WithDefaultSetup(setup), WithDefaultTeardown(teardown))
+		for i := 0; i < 4; i++ {
+			bts.Run(fmt.Sprintf("get car %d", i), HTTPBaselineTest{
+				Handler: rebaselineModeHandler("honda\n"),
+				Method:  "GET",
+				Path:    "/car",
+			})
+		}
+	})
+	return maxRunning
+}
+
+func TestParallelSharedDb(t *testing.T) {
+	dir := os.Getenv(parallelHelperDir)
+	if dir == "" {
+		// -test.parallel defaults to GOMAXPROCS, which may be 1
+		cmd := exec.Command(os.Args[0], "-test.run=^TestParallelSharedDb$", "-test.parallel=4")
+		cmd.Env = append(os.Environ(), parallelHelperDir+"="+t.TempDir())
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("parallel suite failed: %s\n%s", err, out)
+		}
+		return
+	}
+	if n := maxParallel(t, dir, nil); n < 2 {
+		t.Errorf("%d tests without a db ran at once, want them to overlap", n)
+	}
+	if n := maxParallel(t, dir, &sqlx.DB{}); n != 1 {
+		t.Errorf("%d tests sharing a db ran at once, want 1", n)
+	}
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("get_car_%d.resp.txt", i)
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not written: %s", name, err)
+		}
+	}
+}
+
+func TestParallelNameCollision(t *testing.T) {
+	if dir := os.Getenv(parallelHelperDir); dir != "" {
+		bts := NewSuite(t, WithBaselineDir(dir), WithRebaseline(RebaselineAlways), WithParallel())
+		for _, name := range []string{"T1", "t1"} {
+			bts.Run(name, HTTPBaselineTest{
+				Handler: rebaselineModeHandler("honda\n"),
+				Method:  "GET",
+				Path:    "/car",
+			})
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParallelNameCollision$", "-test.v")
+	cmd.Env = append(os.Environ(), parallelHelperDir+"="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("tests with colliding baselines passed:\n%s", out)
+	}
+	if !strings.Contains(string(out), "/t1 is already used by test ") {
+		t.Errorf("collision was not reported:\n%s", out)
+	}
+	if strings.Count(string(out), "--- FAIL: TestParallelNameCollision/") != 1 {
+		t.Errorf("want exactly one of the colliding tests to fail:\n%s", out)
+	}
+}
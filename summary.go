@@ -0,0 +1,176 @@
+package httpbaselinetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// summarizeMismatch describes in one line how a baseline changed, e.g.
+// "status changed 200 -> 500; 2 body fields changed"
+func summarizeMismatch(artifact Artifact, expected string, actual string) string {
+	var changes []string
+	if artifact == ArtifactDb {
+		changes = summarizeDbMismatch(expected, actual)
+	} else {
+		changes = summarizeHTTPMismatch(artifact, expected, actual)
+	}
+	if len(changes) == 0 {
+		return "baseline changed"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// baselineHeaders groups the header lines of a baseline by name.
+// Query parameters, indented under the request line, are grouped
+// separately.
+func baselineHeaders(lines []string) (map[string]string, map[string]string) {
+	headers := make(map[string]string)
+	query := make(map[string]string)
+	for _, line := range lines {
+		name := strings.SplitN(line, ":", 2)[0]
+		if strings.HasPrefix(line, " ") {
+			query[name] += line + "\n"
+		} else {
+			headers[name] += line + "\n"
+		}
+	}
+	return headers, query
+}
+
+func countChanged(expected map[string]string, actual map[string]string) int {
+	changed := 0
+	for name, lines := range expected {
+		if actual[name] != lines {
+			changed++
+		}
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			changed++
+		}
+	}
+	return changed
+}
+
+func summarizeHTTPMismatch(artifact Artifact, expected string, actual string) []string {
+	changes := []string{}
+	expectedHead, expectedBody := splitBaselineBody(artifact, expected)
+	actualHead, actualBody := splitBaselineBody(artifact, actual)
+	expectedLines := strings.Split(strings.TrimSuffix(expectedHead, "\n"), "\n")
+	actualLines := strings.Split(strings.TrimSuffix(actualHead, "\n"), "\n")
+	if expectedLines[0] != actualLines[0] {
+		expectedFields := strings.Fields(expectedLines[0])
+		actualFields := strings.Fields(actualLines[0])
+		switch {
+		case artifact == ArtifactRequest:
+			changes = append(changes, fmt.Sprintf("request line changed %s -> %s",
+				expectedLines[0], actualLines[0]))
+		case len(expectedFields) > 1 && len(actualFields) > 1 &&
+			expectedFields[1] != actualFields[1]:
+			changes = append(changes, fmt.Sprintf("status changed %s -> %s",
+				expectedFields[1], actualFields[1]))
+		default:
+			changes = append(changes, fmt.Sprintf("status line changed %s -> %s",
+				expectedLines[0], actualLines[0]))
+		}
+	}
+	expectedHeaders, expectedQuery := baselineHeaders(expectedLines[1:])
+	actualHeaders, actualQuery := baselineHeaders(actualLines[1:])
+	if changedQuery := countChanged(expectedQuery, actualQuery); changedQuery > 0 {
+		changes = append(changes, plural(changedQuery, "query parameter")+" changed")
+	}
+	if changedHeaders := countChanged(expectedHeaders, actualHeaders); changedHeaders > 0 {
+		changes = append(changes, plural(changedHeaders, "header")+" changed")
+	}
+	if expectedBody != actualBody {
+		ev, expectedJSON := decodeDiffJSON(expectedBody)
+		av, actualJSON := decodeDiffJSON(actualBody)
+		fields := 0
+		if expectedJSON && actualJSON {
+			fields = len(diffJSONValues("$", ev, av, nil))
+		}
+		if fields > 0 {
+			changes = append(changes, plural(fields, "body field")+" changed")
+		} else {
+			changes = append(changes, "body changed")
+		}
+	}
+	return changes
+}
+
+type dbTableSummary struct {
+	AddedRows   []json.RawMessage `json:"addedRows"`
+	RemovedRows []json.RawMessage `json:"removedRows"`
+	UpdatedRows []json.RawMessage `json:"updatedRows"`
+}
+
+func summarizeDbMismatch(expected string, actual string) []string {
+	expectedTables := make(map[string]json.RawMessage)
+	actualTables := make(map[string]json.RawMessage)
+	if json.Unmarshal([]byte(expected), &expectedTables) != nil ||
+		json.Unmarshal([]byte(actual), &actualTables) != nil {
+		return nil
+	}
+	tableNames := []string{}
+	for tableName := range expectedTables {
+		tableNames = append(tableNames, tableName)
+	}
+	for tableName := range actualTables {
+		if _, ok := expectedTables[tableName]; !ok {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	sort.Strings(tableNames)
+	changes := []string{}
+	for _, tableName := range tableNames {
+		e, a := expectedTables[tableName], actualTables[tableName]
+		var et, at dbTableSummary
+		_ = json.Unmarshal(e, &et)
+		_ = json.Unmarshal(a, &at)
+		counted := false
+		for _, count := range []struct {
+			noun     string
+			prep     string
+			expected int
+			actual   int
+		}{
+			{"insert", "into", len(et.AddedRows), len(at.AddedRows)},
+			{"update", "of", len(et.UpdatedRows), len(at.UpdatedRows)},
+			{"delete", "from", len(et.RemovedRows), len(at.RemovedRows)},
+		} {
+			switch {
+			case count.actual > count.expected:
+				changes = append(changes, fmt.Sprintf("%s %s %s",
+					plural(count.actual-count.expected, "unexpected "+count.noun),
+					count.prep, tableName))
+				counted = true
+			case count.actual < count.expected:
+				changes = append(changes, fmt.Sprintf("%s %s %s",
+					plural(count.expected-count.actual, "missing "+count.noun),
+					count.prep, tableName))
+				counted = true
+			}
+		}
+		if !counted && canonicalRawJSON(e) != canonicalRawJSON(a) {
+			changes = append(changes, "rows changed in "+tableName)
+		}
+	}
+	return changes
+}
+
+func canonicalRawJSON(data json.RawMessage) string {
+	var v interface{}
+	if json.Unmarshal(data, &v) != nil {
+		return string(data)
+	}
+	return canonicalJSON(v)
+}
@@ -0,0 +1,115 @@
+package httpbaselinetest
+
+import "testing"
+
+func TestSummarizeMismatch(t *testing.T) {
+	request := "POST /cars?page=1 HTTP/1.1\n    page: 1\nHost: example.com\n" +
+		"Content-Type: application/json\n\n{\n  \"make\": \"Honda\",\n  \"year\": 2020\n}\n"
+	response := "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\n  \"id\": 1\n}\n"
+	tests := []struct {
+		name     string
+		artifact Artifact
+		expected string
+		actual   string
+		summary  string
+	}{
+		{
+			name:     "status",
+			artifact: ArtifactResponse,
+			expected: response,
+			actual:   "HTTP/1.1 500 Internal Server Error\nContent-Type: application/json\n\n{\n  \"id\": 1\n}\n",
+			summary:  "status changed 200 -> 500",
+		},
+		{
+			name:     "reason phrase",
+			artifact: ArtifactResponse,
+			expected: response,
+			actual:   "HTTP/1.1 200 Fine\nContent-Type: application/json\n\n{\n  \"id\": 1\n}\n",
+			summary:  "status line changed HTTP/1.1 200 OK -> HTTP/1.1 200 Fine",
+		},
+		{
+			name:     "short status line",
+			artifact: ArtifactResponse,
+			expected: "\n\nok\n",
+			actual:   response,
+			summary:  "status line changed  -> HTTP/1.1 200 OK; 1 header changed; body changed",
+		},
+		{
+			name:     "request line",
+			artifact: ArtifactRequest,
+			expected: request,
+			actual: "PUT /cars?page=1 HTTP/1.1\n    page: 1\nHost: example.com\n" +
+				"Content-Type: application/json\n\n{\n  \"make\": \"Honda\",\n  \"year\": 2020\n}\n",
+			summary: "request line changed POST /cars?page=1 HTTP/1.1 -> PUT /cars?page=1 HTTP/1.1",
+		},
+		{
+			name:     "query",
+			artifact: ArtifactRequest,
+			expected: request,
+			actual: "POST /cars?page=2&sort=id HTTP/1.1\n    page: 2\n    sort: id\nHost: example.com\n" +
+				"Content-Type: application/json\n\n{\n  \"make\": \"Honda\",\n  \"year\": 2020\n}\n",
+			summary: "request line changed POST /cars?page=1 HTTP/1.1 -> " +
+				"POST /cars?page=2&sort=id HTTP/1.1; 2 query parameters changed",
+		},
+		{
+			name:     "headers",
+			artifact: ArtifactResponse,
+			expected: response,
+			actual: "HTTP/1.1 200 OK\nContent-Type: application/json\nX-Request-Id: 1\n\n" +
+				"{\n  \"id\": 1\n}\n",
+			summary: "1 header changed",
+		},
+		{
+			name:     "body fields",
+			artifact: ArtifactRequest,
+			expected: request,
+			actual: "POST /cars?page=1 HTTP/1.1\n    page: 1\nHost: example.com\n" +
+				"Content-Type: application/json\n\n{\n  \"make\": \"Ford\",\n  \"year\": 1999\n}\n",
+			summary: "2 body fields changed",
+		},
+		{
+			name:     "text body",
+			artifact: ArtifactResponse,
+			expected: "HTTP/1.1 200 OK\n\nhello\n",
+			actual:   "HTTP/1.1 200 OK\n\nbye\n",
+			summary:  "body changed",
+		},
+		{
+			name:     "db counts",
+			artifact: ArtifactDb,
+			expected: `{"cars":{"addedRows":[{"id":1}],"removedRows":[{"id":2}],"updatedRows":[]},` +
+				`"owners":{"addedRows":[],"removedRows":[],"updatedRows":[{"id":1}]}}`,
+			actual: `{"cars":{"addedRows":[{"id":1},{"id":3},{"id":4}],"removedRows":[],"updatedRows":[]},` +
+				`"owners":{"addedRows":[],"removedRows":[],"updatedRows":[]}}`,
+			summary: "2 unexpected inserts into cars; 1 missing delete from cars; " +
+				"1 missing update of owners",
+		},
+		{
+			name:     "db rows",
+			artifact: ArtifactDb,
+			expected: `{"cars":{"addedRows":[{"id":1}]}}`,
+			actual:   `{"cars":{"addedRows":[{"id":2}]}}`,
+			summary:  "rows changed in cars",
+		},
+		{
+			name:     "db table",
+			artifact: ArtifactDb,
+			expected: `{}`,
+			actual:   `{"cars":{"updatedRows":[{"id":1}]}}`,
+			summary:  "1 unexpected update of cars",
+		},
+		{
+			name:     "unchanged",
+			artifact: ArtifactResponse,
+			expected: response,
+			actual:   response,
+			summary:  "baseline changed",
+		},
+	}
+	for _, tt := range tests {
+		got := summarizeMismatch(tt.artifact, tt.expected, tt.actual)
+		if got != tt.summary {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.summary)
+		}
+	}
+}
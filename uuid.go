@@ -0,0 +1,74 @@
+package httpbaselinetest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// UUIDAliasing controls whether UUIDs in baselines are replaced with
+// stable aliases.
+type UUIDAliasing int
+
+const (
+	// UUIDAliasingOff leaves UUIDs as they are. This is the
+	// default.
+	UUIDAliasingOff UUIDAliasing = iota
+	// UUIDAliasingAll replaces each distinct UUID in a test with
+	// <uuid-1>, <uuid-2>, ... in the order they first appear in
+	// the request, response and db baselines.
+	UUIDAliasingAll
+	// UUIDAliasingExceptSeed is like UUIDAliasingAll but leaves
+	// UUIDs from the seed file and the seeded db tables as they
+	// are.
+	UUIDAliasingExceptSeed
+)
+
+var uuidInTextRegexp = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+
+// uuidAliaser assigns aliases to UUIDs for the lifetime of a test,
+// so the same UUID gets the same alias in every baseline
+type uuidAliaser struct {
+	aliases   map[string]string
+	preserved map[string]bool
+}
+
+func newUUIDAliaser() *uuidAliaser {
+	return &uuidAliaser{
+		aliases:   make(map[string]string),
+		preserved: make(map[string]bool),
+	}
+}
+
+// preserve keeps every UUID found in text from being aliased
+func (ua *uuidAliaser) preserve(text string) {
+	for _, u := range uuidInTextRegexp.FindAllString(text, -1) {
+		ua.preserved[strings.ToLower(u)] = true
+	}
+}
+
+func (ua *uuidAliaser) alias(text string) string {
+	return uuidInTextRegexp.ReplaceAllStringFunc(text, func(u string) string {
+		key := strings.ToLower(u)
+		if ua.preserved[key] {
+			return u
+		}
+		alias, ok := ua.aliases[key]
+		if !ok {
+			alias = fmt.Sprintf("<uuid-%d>", len(ua.aliases)+1)
+			ua.aliases[key] = alias
+		}
+		return alias
+	})
+}
+
+// mask replaces the UUIDs in text that would be aliased with <uuid>,
+// so text can be ordered without depending on their random values
+func (ua *uuidAliaser) mask(text string) string {
+	return uuidInTextRegexp.ReplaceAllStringFunc(text, func(u string) string {
+		if ua.preserved[strings.ToLower(u)] {
+			return u
+		}
+		return "<uuid>"
+	})
+}
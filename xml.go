@@ -0,0 +1,143 @@
+package httpbaselinetest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// XMLFormatter indents XML and SOAP bodies, one element per line.
+// Whitespace between elements is dropped and text is trimmed.
+type XMLFormatter struct {
+	// SortAttributes writes attributes in name order instead of
+	// document order
+	SortAttributes bool
+}
+
+type xmlNode struct {
+	// token is a start element, text, comment, processing
+	// instruction or directive
+	token    xml.Token
+	children []*xmlNode
+}
+
+func (f XMLFormatter) FormatBody(mediaType string, params map[string]string, body []byte) (string, error) {
+	root, err := parseXMLNodes(body)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, n := range root.children {
+		f.writeNode(&buf, n, 0)
+	}
+	return buf.String(), nil
+}
+
+// parseXMLNodes builds a tree from raw tokens, so namespace prefixes
+// are kept as written
+func parseXMLNodes(body []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	// the body is already bytes; don't fail on declared charsets
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{token: t.Copy()}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			start, ok := parent.token.(xml.StartElement)
+			if !ok || xmlName(start.Name) != xmlName(t.Name) {
+				return nil, fmt.Errorf("unexpected end element </%s>", xmlName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text != "" {
+				parent.children = append(parent.children, &xmlNode{token: xml.CharData(text)})
+			}
+		default:
+			parent.children = append(parent.children, &xmlNode{token: xml.CopyToken(tok)})
+		}
+	}
+	if len(stack) > 1 {
+		start := stack[len(stack)-1].token.(xml.StartElement)
+		return nil, fmt.Errorf("element <%s> is not closed", xmlName(start.Name))
+	}
+	return root, nil
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;",
+	"\n", "&#xA;", "\t", "&#x9;", "\r", "&#xD;")
+
+func (f XMLFormatter) writeNode(buf *bytes.Buffer, n *xmlNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch t := n.token.(type) {
+	case xml.StartElement:
+		name := xmlName(t.Name)
+		buf.WriteString(indent + "<" + name)
+		attrs := t.Attr
+		if f.SortAttributes {
+			attrs = append([]xml.Attr{}, attrs...)
+			sort.SliceStable(attrs, func(i, j int) bool {
+				return xmlName(attrs[i].Name) < xmlName(attrs[j].Name)
+			})
+		}
+		for _, attr := range attrs {
+			fmt.Fprintf(buf, ` %s="%s"`, xmlName(attr.Name), xmlAttrEscaper.Replace(attr.Value))
+		}
+		switch {
+		case len(n.children) == 0:
+			buf.WriteString("/>\n")
+		case len(n.children) == 1 && isXMLText(n.children[0]):
+			text := n.children[0].token.(xml.CharData)
+			buf.WriteString(">" + xmlTextEscaper.Replace(string(text)) + "</" + name + ">\n")
+		default:
+			buf.WriteString(">\n")
+			for _, child := range n.children {
+				f.writeNode(buf, child, depth+1)
+			}
+			buf.WriteString(indent + "</" + name + ">\n")
+		}
+	case xml.CharData:
+		buf.WriteString(indent + xmlTextEscaper.Replace(string(t)) + "\n")
+	case xml.Comment:
+		buf.WriteString(indent + "<!--" + string(t) + "-->\n")
+	case xml.ProcInst:
+		buf.WriteString(indent + "<?" + t.Target)
+		if len(t.Inst) > 0 {
+			buf.WriteString(" " + string(t.Inst))
+		}
+		buf.WriteString("?>\n")
+	case xml.Directive:
+		buf.WriteString(indent + "<!" + string(t) + ">\n")
+	}
+}
+
+func isXMLText(n *xmlNode) bool {
+	_, ok := n.token.(xml.CharData)
+	return ok
+}
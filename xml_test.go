@@ -0,0 +1,88 @@
+package httpbaselinetest
+
+import (
+	"testing"
+)
+
+func TestXMLFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		sort     bool
+		body     string
+		expected string
+	}{
+		{
+			name:     "indent",
+			body:     "<a>\n  <b>x</b><c/>\n</a>",
+			expected: "<a>\n  <b>x</b>\n  <c/>\n</a>\n",
+		},
+		{
+			name: "namespace prefixes",
+			body: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">` +
+				`<soap:Body><m:Price xmlns:m="urn:cars">10</m:Price></soap:Body></soap:Envelope>`,
+			expected: "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">\n" +
+				"  <soap:Body>\n" +
+				"    <m:Price xmlns:m=\"urn:cars\">10</m:Price>\n" +
+				"  </soap:Body>\n" +
+				"</soap:Envelope>\n",
+		},
+		{
+			name:     "cdata",
+			body:     "<a><![CDATA[x < y && z]]></a>",
+			expected: "<a>x &lt; y &amp;&amp; z</a>\n",
+		},
+		{
+			name:     "comments and processing instructions",
+			body:     "<?xml version=\"1.0\"?>\n<!-- cars --><a><?render fast?><!--b--><b/></a>",
+			expected: "<?xml version=\"1.0\"?>\n<!-- cars -->\n<a>\n  <?render fast?>\n  <!--b-->\n  <b/>\n</a>\n",
+		},
+		{
+			name:     "mixed content",
+			body:     "<a>one<b/>two</a>",
+			expected: "<a>\n  one\n  <b/>\n  two\n</a>\n",
+		},
+		{
+			name:     "attribute escaping",
+			body:     "<a title=\"&quot;x&quot; &amp; &lt;y&gt;\" note=\"line&#xA;tab&#x9;\"/>",
+			expected: "<a title=\"&quot;x&quot; &amp; &lt;y>\" note=\"line&#xA;tab&#x9;\"/>\n",
+		},
+		{
+			name:     "attributes in document order",
+			body:     `<a z="1" b:y="2" x="3"/>`,
+			expected: "<a z=\"1\" b:y=\"2\" x=\"3\"/>\n",
+		},
+		{
+			name:     "sorted attributes",
+			sort:     true,
+			body:     `<a z="1" b:y="2" x="3"><c d="1" a="2"/></a>`,
+			expected: "<a b:y=\"2\" x=\"3\" z=\"1\">\n  <c a=\"2\" d=\"1\"/>\n</a>\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := XMLFormatter{SortAttributes: tt.sort}.FormatBody("application/xml", nil, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: failed: %s", tt.name, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestParseXMLNodesErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"<a><b></a>", "unexpected end element </a>"},
+		{"<a><b/>", "element <a> is not closed"},
+		{"<a></x:a>", "unexpected end element </x:a>"},
+	}
+	for _, tt := range tests {
+		_, err := parseXMLNodes([]byte(tt.body))
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("parseXMLNodes(%q) = %v, want %s", tt.body, err, tt.msg)
+		}
+	}
+}